@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// accountDeletionGracePeriod is how long a deleted account can still be restored before it is purged
+func accountDeletionGracePeriod() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_DAYS"))
+	if err != nil || days < 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// userOwnedModels lists every table with rows owned by a user (via user_id), purged with the account
+var userOwnedModels = []interface{}{
+	&TransactionAnomaly{}, // Before transactions, which it references
+	&Transaction{},
+	&Budget{},
+	&EnvelopeAssignment{},
+	&BudgetTemplate{},
+	&UserSettings{},
+	&UserToken{},
+	&RecoveryCode{},
+	&UserIdentity{},
+	&PersonalAccessToken{},
+	&Tag{},
+	&IdempotencyKey{},
+	&Rule{},
+	&ClassifierCategoryCount{},
+	&ClassifierTokenCount{},
+	&PayeeAlias{}, // After transactions, which reference payees
+	&Payee{},
+}
+
+// purgeUser permanently deletes a user and all rows they own
+func purgeUser(tx *gorm.DB, userID uint) error {
+	// The tag join table has no user_id of its own
+	if err := tx.Exec("DELETE FROM transaction_tag WHERE tag_id IN (SELECT id FROM tag WHERE user_id = ?)", userID).Error; err != nil {
+		return err
+	}
+	for _, model := range userOwnedModels {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	// Audit entries hold copies of the user's data, so they are erased too
+	if err := tx.Where("actor_id = ? OR (entity_type = ? AND entity_id = ?)", userID, AuditEntityUser, fmt.Sprint(userID)).Delete(&AuditLog{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(&User{}, userID).Error
+}
+
+// purgeDeletedAccounts hard-deletes accounts whose deletion grace period has passed
+func purgeDeletedAccounts() {
+	var userIDs []uint
+	cutoff := time.Now().Add(-accountDeletionGracePeriod())
+	if err := DB.Unscoped().Model(&User{}).Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Pluck("id", &userIDs).Error; err != nil {
+		log.Println("Failed to list accounts to purge:", err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		if err := DB.Transaction(func(tx *gorm.DB) error { return purgeUser(tx, userID) }); err != nil {
+			log.Printf("Failed to purge account %d: %v", userID, err)
+			continue
+		}
+		log.Printf("🗑️ Purged account %d", userID)
+	}
+}
+
+// UpdateUser changes the authenticated user's name and email; a new email must be verified again
+func UpdateUser(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name  string `json:"name" binding:"required"`
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	emailChanged := input.Email != user.Email
+	if emailChanged {
+		// Soft-deleted accounts keep their email reserved until they are purged
+		var count int64
+		DB.Unscoped().Model(&User{}).Where("email = ? AND id <> ?", input.Email, user.ID).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": T(c, "Email is already in use")})
+			return
+		}
+	}
+
+	before := *user
+	oldEmail := user.Email
+	updates := map[string]interface{}{"name": input.Name, "email": input.Email}
+	if emailChanged {
+		updates["email_verified_at"] = nil
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(updates).Error; err != nil {
+			return err
+		}
+		user.Name = input.Name
+		user.Email = input.Email
+		if emailChanged {
+			user.EmailVerifiedAt = nil
+		}
+		return recordAudit(tx, c, AuditActionUpdate, AuditEntityUser, user.ID, before, *user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update user")})
+		return
+	}
+
+	if emailChanged {
+		if err := sendVerificationEmail(user); err != nil {
+			log.Println("Failed to send verification email:", err)
+		}
+		// Let the previous address know, in case the change was not made by its owner
+		body := "Hi " + user.Name + ",\n\nThe email address of your GoBudget account was changed to " + user.Email + ".\nIf you did not make this change, please reset your password immediately.\n"
+		if err := AppMailer.Send(oldEmail, "Your GoBudget email address was changed", body); err != nil {
+			log.Println("Failed to send email change notice:", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":             user.ID,
+		"email":          user.Email,
+		"name":           user.Name,
+		"email_verified": user.EmailVerifiedAt != nil,
+	})
+}
+
+// ChangePassword sets a new password after checking the current one
+func ChangePassword(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required,min=8"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.CurrentPassword)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid credentials")})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to hash password")})
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("password", string(hashedPassword)).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(tx, c, AuditActionChangePassword, AuditEntityUser, user.ID, nil, nil); err != nil {
+			return err
+		}
+		// Outstanding reset links were issued for the old password
+		return tx.Model(&UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, TokenPurposeResetPassword).
+			Update("used_at", time.Now()).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to change password")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Password changed")})
+}
+
+// DeleteUser schedules the authenticated user's account for deletion after the grace period
+func DeleteUser(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid credentials")})
+		return
+	}
+
+	// Soft delete now; purgeDeletedAccounts removes the account and its data once the grace period ends
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(user).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionDelete, AuditEntityUser, user.ID, nil, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to delete account")})
+		return
+	}
+
+	setAuthCookie(c, "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  T(c, "Account scheduled for deletion"),
+		"purge_at": time.Now().Add(accountDeletionGracePeriod()),
+	})
+}
+
+// RestoreUser cancels a pending account deletion during the grace period
+func RestoreUser(c *gin.Context) {
+	var input AuthRequest
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Invalid request")})
+		return
+	}
+
+	var user User
+	cutoff := time.Now().Add(-accountDeletionGracePeriod())
+	err := DB.Unscoped().Where("email = ? AND deleted_at IS NOT NULL AND deleted_at >= ?", input.Email, cutoff).First(&user).Error
+	if err != nil || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid credentials")})
+		return
+	}
+
+	// Record the restoring user as the actor even though the request is unauthenticated
+	c.Set("userID", user.ID)
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&user).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionRestore, AuditEntityUser, user.ID, nil, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to restore account")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Account restored")})
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AdminGetUsers lists users (including deleted ones), optionally searching by name or email
+func AdminGetUsers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 200 {
+		perPage = 50
+	}
+
+	query := DB.Unscoped().Model(&User{})
+	if search := strings.TrimSpace(c.Query("search")); search != "" {
+		pattern := "%" + search + "%"
+		query = query.Where("name ILIKE ? OR email ILIKE ?", pattern, pattern)
+	}
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+
+	var total int64
+	var users []User
+	query.Count(&total)
+	if err := query.Order("id ASC").Offset((page - 1) * perPage).Limit(perPage).Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch users")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users, "total": total, "page": page, "per_page": perPage})
+}
+
+// AdminDisableUser blocks a user from logging in or using existing sessions and tokens
+func AdminDisableUser(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+	if c.Param("id") == strconv.FormatUint(uint64(adminID.(uint)), 10) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "You cannot disable your own account")})
+		return
+	}
+
+	var user User
+	if err := DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "User not found")})
+		return
+	}
+
+	before := user
+	now := time.Now()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("disabled_at", now).Error; err != nil {
+			return err
+		}
+		user.DisabledAt = &now
+		return recordAudit(tx, c, AuditActionDisable, AuditEntityUser, user.ID, before, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update user")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "User disabled"), "disabled_at": now})
+}
+
+// AdminRestoreUser re-enables a disabled user and cancels a pending account deletion
+func AdminRestoreUser(c *gin.Context) {
+	var user User
+	if err := DB.Unscoped().First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "User not found")})
+		return
+	}
+
+	before := user
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&user).Updates(map[string]interface{}{"disabled_at": nil, "deleted_at": nil}).Error; err != nil {
+			return err
+		}
+		user.DisabledAt = nil
+		user.DeletedAt = gorm.DeletedAt{}
+		return recordAudit(tx, c, AuditActionRestore, AuditEntityUser, user.ID, before, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update user")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "User restored")})
+}
+
+// errLastAdmin is returned when a role change would leave no active admin to manage the deployment
+var errLastAdmin = errors.New("cannot demote the last admin")
+
+// AdminSetUserRole changes a user's role. The last active admin cannot be demoted, since admins
+// are otherwise only created by the seeder.
+func AdminSetUserRole(c *gin.Context) {
+	var input struct {
+		Role string `json:"role" binding:"required,oneof=user admin"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user User
+	if err := DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "User not found")})
+		return
+	}
+
+	before := user
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if user.Role == "admin" && input.Role != "admin" {
+			// Lock the admin rows so that two admins cannot demote each other at the same time
+			var admins []uint
+			if err := tx.Model(&User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("role = ? AND disabled_at IS NULL AND id <> ?", "admin", user.ID).
+				Pluck("id", &admins).Error; err != nil {
+				return err
+			}
+			if len(admins) == 0 {
+				return errLastAdmin
+			}
+		}
+		if err := tx.Model(&user).Update("role", input.Role).Error; err != nil {
+			return err
+		}
+		user.Role = input.Role
+		return recordAudit(tx, c, AuditActionUpdate, AuditEntityUser, user.ID, before, user)
+	})
+	if errors.Is(err, errLastAdmin) {
+		c.JSON(http.StatusConflict, gin.H{"error": T(c, "Cannot demote the last admin")})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update user")})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// AdminGetUserStats reports how much a user uses the service
+func AdminGetUserStats(c *gin.Context) {
+	var user User
+	if err := DB.Unscoped().First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "User not found")})
+		return
+	}
+
+	var transactionCount, deletedTransactionCount, budgetCount, tokenCount int64
+	var totalIncome, totalExpense sql.NullFloat64
+	var lastActivity sql.NullTime
+
+	DB.Model(&Transaction{}).Where("user_id = ?", user.ID).Count(&transactionCount)
+	DB.Unscoped().Model(&Transaction{}).Where("user_id = ? AND deleted_at IS NOT NULL", user.ID).Count(&deletedTransactionCount)
+	DB.Model(&Budget{}).Where("user_id = ?", user.ID).Count(&budgetCount)
+	DB.Model(&PersonalAccessToken{}).Where("user_id = ?", user.ID).Count(&tokenCount)
+
+	err1 := DB.Model(&Transaction{}).
+		Where("user_id = ? AND type = ?", user.ID, "Income").
+		Select("COALESCE(SUM(amount * exchange_rate), 0)").Scan(&totalIncome).Error
+	err2 := DB.Model(&Transaction{}).
+		Where("user_id = ? AND type = ?", user.ID, "Expense").
+		Select("COALESCE(SUM(amount * exchange_rate), 0)").Scan(&totalExpense).Error
+	err3 := DB.Unscoped().Model(&Transaction{}).
+		Where("user_id = ?", user.ID).
+		Select("MAX(updated_at)").Scan(&lastActivity).Error
+
+	if err1 != nil || err2 != nil || err3 != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch summary data")})
+		return
+	}
+
+	stats := gin.H{
+		"user_id":                   user.ID,
+		"transaction_count":         transactionCount,
+		"deleted_transaction_count": deletedTransactionCount,
+		"budget_count":              budgetCount,
+		"token_count":               tokenCount,
+		"total_income":              totalIncome.Float64,
+		"total_expense":             totalExpense.Float64,
+		"last_activity_at":          nil,
+		"registered_at":             user.CreatedAt,
+	}
+	if lastActivity.Valid {
+		stats["last_activity_at"] = lastActivity.Time
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// AdminUpdateCategory renames a system category
+func AdminUpdateCategory(c *gin.Context) {
+	var category Category
+	if err := DB.First(&category, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Category not found")})
+		return
+	}
+
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := category
+	category.Name = input.Name
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&category).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionUpdate, AuditEntityCategory, category.ID, before, category)
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": T(c, "Category already exists")})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update category")})
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// AdminDeleteCategory removes a system category that no transaction, budget or rule uses
+func AdminDeleteCategory(c *gin.Context) {
+	var category Category
+	if err := DB.First(&category, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Category not found")})
+		return
+	}
+
+	// Deleted rows still reference the category, so count them too
+	var transactionCount, budgetCount, ruleCount, envelopeCount int64
+	DB.Unscoped().Model(&Transaction{}).Where("category_id = ?", category.ID).Count(&transactionCount)
+	DB.Unscoped().Model(&Budget{}).Where("category_id = ?", category.ID).Count(&budgetCount)
+	DB.Model(&Rule{}).Where("category_id = ?", category.ID).Count(&ruleCount)
+	DB.Model(&EnvelopeAssignment{}).Where("category_id = ? OR counterpart_category_id = ?", category.ID, category.ID).Count(&envelopeCount)
+	if transactionCount > 0 || budgetCount > 0 || ruleCount > 0 || envelopeCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": T(c, "Category is in use")})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&category).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionDelete, AuditEntityCategory, category.ID, category, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to delete category")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Category deleted")})
+}
+
+// GetExchangeRates lists the system exchange rates to IDR
+func GetExchangeRates(c *gin.Context) {
+	var rates []ExchangeRate
+	if err := DB.Order("currency ASC").Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch exchange rates")})
+		return
+	}
+	c.JSON(http.StatusOK, rates)
+}
+
+// AdminSetExchangeRate creates or updates the rate of a currency
+func AdminSetExchangeRate(c *gin.Context) {
+	var input struct {
+		RateToIDR float64 `json:"rate_to_idr" binding:"required,gt=0"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency := strings.ToUpper(c.Param("currency"))
+	if len(currency) != 3 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Invalid currency code")})
+		return
+	}
+
+	var before *ExchangeRate
+	var existing ExchangeRate
+	if err := DB.First(&existing, "currency = ?", currency).Error; err == nil {
+		before = &existing
+	}
+
+	rate := ExchangeRate{Currency: currency, RateToIDR: input.RateToIDR}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&rate).Error; err != nil {
+			return err
+		}
+		action := AuditActionUpdate
+		if before == nil {
+			action = AuditActionCreate
+		}
+		return recordAudit(tx, c, action, AuditEntityExchangeRate, rate.Currency, before, rate)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update exchange rate")})
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
+
+// AdminDeleteExchangeRate removes the rate of a currency
+func AdminDeleteExchangeRate(c *gin.Context) {
+	var rate ExchangeRate
+	if err := DB.First(&rate, "currency = ?", strings.ToUpper(c.Param("currency"))).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Exchange rate not found")})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&rate).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionDelete, AuditEntityExchangeRate, rate.Currency, rate, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update exchange rate")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Exchange rate deleted")})
+}
+
+// isUniqueViolation reports whether err comes from a unique constraint (e.g. a duplicate category name)
+func isUniqueViolation(err error) bool {
+	return errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "SQLSTATE 23505")
+}
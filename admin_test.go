@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminSetUserRoleKeepsOneAdmin(t *testing.T) {
+	setupTestDB(t, &User{}, &AuditLog{})
+
+	first := User{Name: "First", Email: "first@example.com", Role: "admin"}
+	second := User{Name: "Second", Email: "second@example.com", Role: "admin"}
+	for _, user := range []*User{&first, &second} {
+		if err := DB.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/admin/users/:id/role", func(c *gin.Context) { c.Set("userID", first.ID) }, AdminSetUserRole)
+	setRole := func(user User, role string) int {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodPut, "/admin/users/"+strconv.Itoa(int(user.ID))+"/role", strings.NewReader(`{"role":"`+role+`"}`)))
+		return response.Code
+	}
+
+	// An admin may step down while another admin remains
+	if code := setRole(first, "user"); code != http.StatusOK {
+		t.Fatalf("demoting one of two admins: status %d, want 200", code)
+	}
+	if code := setRole(second, "user"); code != http.StatusConflict {
+		t.Fatalf("demoting the last admin: status %d, want 409", code)
+	}
+
+	var admins int64
+	DB.Model(&User{}).Where("role = ?", "admin").Count(&admins)
+	if admins != 1 {
+		t.Fatalf("%d admins left, want 1", admins)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AuditEntityAnomaly identifies anomaly flags in the audit log
+const AuditEntityAnomaly = "transaction_anomaly"
+
+// Kinds of anomaly flagged on transactions
+const (
+	AnomalyAmountOutlier = "amount_outlier"  // Far above the user's usual spending in the category
+	AnomalyDuplicate     = "duplicate"       // Same amount and payee or note as a recent transaction
+	AnomalyNewPayeeLarge = "new_payee_large" // First transaction with a payee, and a large one
+)
+
+const (
+	anomalyLookback         = 365 * 24 * time.Hour // History the statistics are computed over
+	anomalyMinSamples       = 5                    // Category expenses needed before outliers are flagged
+	anomalyMinPayeeSamples  = 10                   // Expenses needed before "large" means anything
+	anomalyDuplicateWindow  = 3 * 24 * time.Hour   // How close in time a duplicate charge must be
+	anomalyLargePercentile  = 0.9                  // A new payee's charge is large above this percentile
+	defaultAnomalyThreshold = 3.0                  // Standard deviations above the mean for an outlier
+)
+
+// anomalyThreshold is how many standard deviations above the category mean an expense must be
+// to be flagged as an outlier
+func anomalyThreshold() float64 {
+	threshold, err := strconv.ParseFloat(os.Getenv("ANOMALY_STDDEV_THRESHOLD"), 64)
+	if err != nil || threshold <= 0 {
+		return defaultAnomalyThreshold
+	}
+	return threshold
+}
+
+// detectAnomalies checks an expense against the user's own history and returns the flags it earns
+func detectAnomalies(t *Transaction) ([]TransactionAnomaly, error) {
+	if t.Type != "Expense" {
+		return nil, nil
+	}
+
+	var anomalies []TransactionAnomaly
+	amount := t.Amount * t.ExchangeRate
+	since := t.OccurredAt.Add(-anomalyLookback)
+	history := DB.Model(&Transaction{}).
+		Where("user_id = ? AND type = ? AND id <> ? AND deleted_at IS NULL", t.UserID, "Expense", t.ID).
+		Where("occurred_at >= ? AND occurred_at <= ?", since, t.OccurredAt)
+
+	// Amount far above the user's norm for the category
+	if t.CategoryID != nil {
+		var stats struct {
+			Samples int
+			Mean    sql.NullFloat64
+			Stddev  sql.NullFloat64
+		}
+		err := history.Session(&gorm.Session{}).Where("category_id = ?", *t.CategoryID).
+			Select("COUNT(*) AS samples, AVG(amount * exchange_rate) AS mean, STDDEV_SAMP(amount * exchange_rate) AS stddev").
+			Scan(&stats).Error
+		if err != nil {
+			return nil, err
+		}
+		if stats.Samples >= anomalyMinSamples && stats.Stddev.Float64 > 0 {
+			score := (amount - stats.Mean.Float64) / stats.Stddev.Float64
+			if score >= anomalyThreshold() {
+				anomalies = append(anomalies, TransactionAnomaly{
+					Kind:    AnomalyAmountOutlier,
+					Score:   score,
+					Details: fmt.Sprintf("%.1f standard deviations above the category average of %.2f IDR", score, stats.Mean.Float64),
+				})
+			}
+		}
+	}
+
+	// A charge that looks like one already recorded
+	duplicates := DB.Model(&Transaction{}).
+		Where("user_id = ? AND type = ? AND id <> ? AND deleted_at IS NULL", t.UserID, t.Type, t.ID).
+		Where("amount = ? AND currency = ?", t.Amount, t.Currency).
+		Where("occurred_at BETWEEN ? AND ?", t.OccurredAt.Add(-anomalyDuplicateWindow), t.OccurredAt.Add(anomalyDuplicateWindow))
+	if t.PayeeID != nil {
+		duplicates = duplicates.Where("payee_id = ?", *t.PayeeID)
+	} else {
+		duplicates = duplicates.Where("LOWER(note) = ?", strings.ToLower(t.Note))
+	}
+	var duplicateID uint
+	if err := duplicates.Order("occurred_at DESC").Limit(1).Pluck("id", &duplicateID).Error; err != nil {
+		return nil, err
+	}
+	if duplicateID != 0 {
+		anomalies = append(anomalies, TransactionAnomaly{
+			Kind:    AnomalyDuplicate,
+			Score:   1,
+			Details: fmt.Sprintf("Looks like a duplicate of transaction %d", duplicateID),
+		})
+	}
+
+	// A large first charge from a payee the user has never paid before
+	if t.PayeeID != nil {
+		var previous int64
+		if err := DB.Unscoped().Model(&Transaction{}).Where("payee_id = ? AND id <> ?", *t.PayeeID, t.ID).Count(&previous).Error; err != nil {
+			return nil, err
+		}
+		if previous == 0 {
+			var stats struct {
+				Samples    int
+				Percentile sql.NullFloat64
+			}
+			err := history.Session(&gorm.Session{}).
+				Select("COUNT(*) AS samples, PERCENTILE_CONT(?) WITHIN GROUP (ORDER BY amount * exchange_rate) AS percentile", anomalyLargePercentile).
+				Scan(&stats).Error
+			if err != nil {
+				return nil, err
+			}
+			if stats.Samples >= anomalyMinPayeeSamples && stats.Percentile.Float64 > 0 && amount >= stats.Percentile.Float64 {
+				anomalies = append(anomalies, TransactionAnomaly{
+					Kind:    AnomalyNewPayeeLarge,
+					Score:   amount / stats.Percentile.Float64,
+					Details: fmt.Sprintf("First transaction with this payee, larger than %d%% of your expenses", int(anomalyLargePercentile*100)),
+				})
+			}
+		}
+	}
+
+	for i := range anomalies {
+		anomalies[i].UserID = t.UserID
+		anomalies[i].TransactionID = t.ID
+	}
+	return anomalies, nil
+}
+
+// flagAnomalies runs anomaly detection on created or edited transactions, stores new flags, drops
+// flags an edit made obsolete and, for users who opted in, emails an alert about the new ones. It
+// runs after the transactions are committed, so a failure here is logged rather than failing the
+// request. A flag the user dismissed stays dismissed for as long as it still applies.
+func flagAnomalies(transactions []*Transaction) {
+	var flagged []TransactionAnomaly
+	for _, t := range transactions {
+		anomalies, err := detectAnomalies(t)
+		if err != nil {
+			log.Printf("Failed to check transaction %d for anomalies: %v", t.ID, err)
+			continue
+		}
+
+		stale := DB.Where("transaction_id = ?", t.ID)
+		if len(anomalies) > 0 {
+			kinds := make([]string, len(anomalies))
+			for i, anomaly := range anomalies {
+				kinds[i] = anomaly.Kind
+			}
+			stale = stale.Where("kind NOT IN ?", kinds)
+		}
+		if err := stale.Delete(&TransactionAnomaly{}).Error; err != nil {
+			log.Printf("Failed to clear anomalies for transaction %d: %v", t.ID, err)
+			continue
+		}
+
+		// Flags the transaction already had are kept as they are and not alerted again
+		for i := range anomalies {
+			result := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&anomalies[i])
+			if result.Error != nil {
+				log.Printf("Failed to store anomalies for transaction %d: %v", t.ID, result.Error)
+				break
+			}
+			if result.RowsAffected == 1 {
+				flagged = append(flagged, anomalies[i])
+			}
+		}
+		t.Anomalies = anomalies
+	}
+
+	if len(flagged) > 0 {
+		notifyAnomalies(flagged[0].UserID, flagged)
+	}
+}
+
+// notifyAnomalies emails the user about new anomalies if they turned anomaly alerts on
+func notifyAnomalies(userID uint, anomalies []TransactionAnomaly) {
+	if !loadUserSettings(userID).AnomalyAlerts {
+		return
+	}
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		return
+	}
+
+	var lines strings.Builder
+	for _, anomaly := range anomalies {
+		fmt.Fprintf(&lines, "- Transaction %d: %s\n", anomaly.TransactionID, anomaly.Details)
+	}
+	body := fmt.Sprintf("Hi %s,\n\nWe noticed some unusual activity in your GoBudget transactions:\n\n%s\nReview them at %s/insights.\n",
+		user.Name, lines.String(), appURL())
+	if err := AppMailer.Send(user.Email, "Unusual transactions in your GoBudget account", body); err != nil {
+		log.Println("Failed to send anomaly alert:", err)
+	}
+}
+
+// GetAnomalies lists the user's transactions with open anomaly flags, newest first. Dismissed flags
+// are included with ?include_dismissed=true, and ?from=&to= (YYYY-MM-DD) limit the period.
+func GetAnomalies(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	flags := DB.Model(&TransactionAnomaly{}).Select("transaction_id").Where("user_id = ?", userID)
+	preloadCondition := "dismissed_at IS NULL"
+	if c.Query("include_dismissed") == "true" {
+		preloadCondition = "TRUE"
+	} else {
+		flags = flags.Where("dismissed_at IS NULL")
+	}
+
+	query := DB.Preload("Category").Preload("Payee").Preload("Anomalies", preloadCondition).
+		Where("user_id = ? AND deleted_at IS NULL AND id IN (?)", userID, flags)
+	if c.Query("from") != "" || c.Query("to") != "" {
+		start, end, err := reportPeriod(c, userLocation(userID.(uint)))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query = query.Where("occurred_at >= ? AND occurred_at < ?", start, end)
+	}
+
+	var transactions []Transaction
+	if err := query.Order("occurred_at DESC").Find(&transactions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch anomalies")})
+		return
+	}
+
+	c.JSON(http.StatusOK, transactions)
+}
+
+// DismissAnomaly marks an anomaly flag as reviewed so it no longer shows up
+func DismissAnomaly(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var anomaly TransactionAnomaly
+	if err := DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&anomaly).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Anomaly not found")})
+		return
+	}
+
+	before := anomaly
+	now := time.Now()
+	anomaly.DismissedAt = &now
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&anomaly).Update("dismissed_at", now).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionUpdate, AuditEntityAnomaly, anomaly.ID, before, anomaly)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to dismiss anomaly")})
+		return
+	}
+
+	c.JSON(http.StatusOK, anomaly)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFlagAnomaliesDropsFlagsAnEditMadeObsolete(t *testing.T) {
+	setupTestDB(t, &Transaction{}, &TransactionAnomaly{}, &UserSettings{})
+
+	at := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	original := Transaction{UserID: 1, Type: "Expense", Amount: 50000, Currency: "IDR", ExchangeRate: 1, Note: "Parking", OccurredAt: at, Version: 1}
+	repeat := original
+	repeat.OccurredAt = at.Add(time.Hour)
+	for _, transaction := range []*Transaction{&original, &repeat} {
+		if err := DB.Create(transaction).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	flagAnomalies([]*Transaction{&repeat})
+	var flags []TransactionAnomaly
+	DB.Where("transaction_id = ?", repeat.ID).Find(&flags)
+	if len(flags) != 1 || flags[0].Kind != AnomalyDuplicate {
+		t.Fatalf("flags = %+v, want a duplicate flag", flags)
+	}
+
+	// Checking again keeps the existing flag, including its dismissal
+	DB.Model(&flags[0]).Update("dismissed_at", time.Now())
+	flagAnomalies([]*Transaction{&repeat})
+	var kept TransactionAnomaly
+	if err := DB.Where("transaction_id = ?", repeat.ID).First(&kept).Error; err != nil || kept.ID != flags[0].ID || kept.DismissedAt == nil {
+		t.Fatalf("flag after recheck = %+v, err %v; want the dismissed flag kept", kept, err)
+	}
+
+	// Once the amount is corrected it is no longer a duplicate
+	repeat.Amount = 75000
+	if err := DB.Model(&Transaction{}).Where("id = ?", repeat.ID).Update("amount", repeat.Amount).Error; err != nil {
+		t.Fatal(err)
+	}
+	flagAnomalies([]*Transaction{&repeat})
+	var remaining int64
+	DB.Model(&TransactionAnomaly{}).Where("transaction_id = ?", repeat.ID).Count(&remaining)
+	if remaining != 0 {
+		t.Fatalf("%d flags left after the edit, want none", remaining)
+	}
+}
+
+func TestApplyRulesFlagsChangedTransactions(t *testing.T) {
+	setupTestDB(t, &Transaction{}, &Tag{}, &Rule{}, &TransactionAnomaly{}, &AuditLog{}, &UserSettings{}, &ClassifierCategoryCount{}, &ClassifierTokenCount{})
+
+	at := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	rule := Rule{UserID: 1, Name: "Parking", Enabled: true, Tags: []string{"car"},
+		Conditions: []RuleCondition{{Field: RuleFieldNote, Operator: RuleOpContains, Value: "parking"}}}
+	original := Transaction{UserID: 1, Type: "Expense", Amount: 50000, Currency: "IDR", ExchangeRate: 1, Note: "Parking", OccurredAt: at, Version: 1}
+	repeat := original
+	repeat.OccurredAt = at.Add(time.Hour)
+	for _, record := range []interface{}{&rule, &original, &repeat} {
+		if err := DB.Create(record).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/rules/apply", func(c *gin.Context) { c.Set("userID", uint(1)) }, ApplyRules)
+	body := fmt.Sprintf(`{"filter": {"ids": [%d]}}`, repeat.ID)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/rules/apply", strings.NewReader(body)))
+	if response.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", response.Code, response.Body.String())
+	}
+
+	var flags []TransactionAnomaly
+	DB.Where("transaction_id = ?", repeat.ID).Find(&flags)
+	if len(flags) != 1 || flags[0].Kind != AnomalyDuplicate {
+		t.Fatalf("flags = %+v, want a duplicate flag on the transaction the rule changed", flags)
+	}
+
+	// A dry run changes nothing, so it checks nothing either
+	DB.Where("transaction_id = ?", repeat.ID).Delete(&TransactionAnomaly{})
+	DB.Model(&Rule{}).Where("id = ?", rule.ID).Update("tags", `["car","parking"]`)
+	body = fmt.Sprintf(`{"filter": {"ids": [%d]}, "dry_run": true}`, repeat.ID)
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/rules/apply", strings.NewReader(body)))
+	var remaining int64
+	DB.Model(&TransactionAnomaly{}).Where("transaction_id = ?", repeat.ID).Count(&remaining)
+	if response.Code != http.StatusOK || remaining != 0 {
+		t.Fatalf("dry run: status %d, %d flags; want 200 and no flags", response.Code, remaining)
+	}
+}
+
+func TestRevertTransactionFlagsTheRestoredVersion(t *testing.T) {
+	setupTestDB(t, &Transaction{}, &TransactionAnomaly{}, &AuditLog{}, &UserSettings{}, &ClassifierCategoryCount{}, &ClassifierTokenCount{})
+
+	at := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	original := Transaction{UserID: 1, Type: "Expense", Amount: 50000, Currency: "IDR", ExchangeRate: 1, Note: "Parking", OccurredAt: at, Version: 1}
+	repeat := original
+	repeat.OccurredAt = at.Add(time.Hour)
+	for _, transaction := range []*Transaction{&original, &repeat} {
+		if err := DB.Create(transaction).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recordAudit(DB, nil, AuditActionCreate, AuditEntityTransaction, repeat.ID, nil, repeat); err != nil {
+		t.Fatal(err)
+	}
+
+	// Version 2 corrected the amount; reverting to version 1 makes it a duplicate again
+	if err := DB.Model(&Transaction{}).Where("id = ?", repeat.ID).Updates(map[string]interface{}{"amount": 75000, "version": 2}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/transactions/:id/revert", func(c *gin.Context) { c.Set("userID", uint(1)) }, RevertTransaction)
+	request := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/transactions/%d/revert?version=1", repeat.ID), nil)
+	request.Header.Set("If-Match", entityETag(2))
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", response.Code, response.Body.String())
+	}
+
+	var reverted Transaction
+	if err := json.Unmarshal(response.Body.Bytes(), &reverted); err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted.Anomalies) != 1 || reverted.Anomalies[0].Kind != AnomalyDuplicate {
+		t.Fatalf("anomalies = %+v, want the restored version flagged as a duplicate", reverted.Anomalies)
+	}
+}
@@ -2,29 +2,34 @@ package main
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // GetTransactions retrieves transactions for the authenticated user
 func GetTransactions(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
 		return
 	}
 
 	var transactions []Transaction
-	query := DB.Preload("Category").Where("user_id = ? AND deleted_at IS NULL", userID)
+	query := DB.Preload("Category").Preload("Tags").Preload("Payee").Where("user_id = ? AND deleted_at IS NULL", userID)
 
-	// Apply filters if provided
+	// Apply filters if provided, with dates taken as whole days in the user's timezone
+	loc := userLocation(userID.(uint))
 	if startDateStr, endDateStr := c.Query("start_date"), c.Query("end_date"); startDateStr != "" && endDateStr != "" {
-		startDate, err1 := time.Parse("2006-01-02", startDateStr)
-		endDate, err2 := time.Parse("2006-01-02", endDateStr)
+		startDate, err1 := time.ParseInLocation("2006-01-02", startDateStr, loc)
+		endDate, err2 := time.ParseInLocation("2006-01-02", endDateStr, loc)
 		if err1 == nil && err2 == nil {
-			query = query.Where("created_at >= ? AND created_at <= ?", startDate, endDate)
+			// The end date is inclusive, so compare against the start of the following day
+			query = query.Where("occurred_at >= ? AND occurred_at < ?", startDate, endDate.AddDate(0, 0, 1))
 		}
 	}
 
@@ -35,7 +40,7 @@ func GetTransactions(c *gin.Context) {
 		query = query.Where("type = ?", txType)
 	}
 
-	query.Find(&transactions)
+	query.Order("occurred_at DESC").Find(&transactions)
 	c.JSON(http.StatusOK, transactions)
 }
 
@@ -43,13 +48,17 @@ func GetTransactions(c *gin.Context) {
 func GetTransactionByID(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
 		return
 	}
 
 	var transaction Transaction
-	if err := DB.Preload("Category").Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&transaction).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+	if err := DB.Preload("Category").Preload("Tags").Preload("Payee").Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&transaction).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Transaction not found")})
+		return
+	}
+
+	if notModified(c, transaction.Version) {
 		return
 	}
 
@@ -60,41 +69,62 @@ func GetTransactionByID(c *gin.Context) {
 func CreateTransaction(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
 		return
 	}
 
-	var input struct {
-		Type         string  `json:"type" binding:"required"`
-		Amount       float64 `json:"amount" binding:"required"`
-		Currency     string  `json:"currency" binding:"required"`
-		ExchangeRate float64 `json:"exchange_rate" binding:"required"`
-		Note         string  `json:"note"`
-		CategoryID   uint    `json:"category_id"`
-	}
-
+	var input transactionInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	transaction := Transaction{
-		Type:         input.Type,
-		Amount:       input.Amount,
-		Currency:     input.Currency,
-		ExchangeRate: input.ExchangeRate,
-		Note:         input.Note,
-		CategoryID:   &input.CategoryID,
-		UserID:       userID.(uint),
+		UserID:     userID.(uint),
+		OccurredAt: time.Now(), // Used when the input has no occurred_at
+		Version:    1,
+	}
+	loc := userLocation(userID.(uint))
+	if err := input.apply(&transaction, loc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	if err := DB.Create(&transaction).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction"})
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		tags, err := applyRulesOnCreate(tx, &transaction)
+		if err != nil {
+			return err
+		}
+		if err := setTransactionPayee(tx, &transaction, input.Payee); err != nil {
+			return err
+		}
+		if err := tx.Create(&transaction).Error; err != nil {
+			return err
+		}
+		if err := attachTags(tx, c, &transaction, tags); err != nil {
+			return err
+		}
+		if err := trainClassifier(tx, &transaction); err != nil {
+			return err
+		}
+		if err := refreshPercentBudgets(tx, c, &transaction, loc); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionCreate, AuditEntityTransaction, transaction.ID, nil, transaction)
+	})
+	if errors.Is(err, errPayeeNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Payee not found")})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to create transaction")})
 		return
 	}
 
-	DB.Preload("Category").First(&transaction, transaction.ID)
+	flagAnomalies([]*Transaction{&transaction})
+	DB.Preload("Category").Preload("Tags").Preload("Payee").Preload("Anomalies").First(&transaction, transaction.ID)
 
+	setETag(c, transaction.Version)
 	c.JSON(http.StatusCreated, transaction)
 }
 
@@ -102,44 +132,134 @@ func CreateTransaction(c *gin.Context) {
 func UpdateTransaction(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
 		return
 	}
 
 	var transaction Transaction
 	if err := DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&transaction).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Transaction not found")})
 		return
 	}
 
-	var input struct {
-		Type         string  `json:"type"`
-		Amount       float64 `json:"amount"`
-		Currency     string  `json:"currency"`
-		ExchangeRate float64 `json:"exchange_rate"`
-		Note         string  `json:"note"`
-		CategoryID   uint    `json:"category_id"`
+	if !checkIfMatch(c, transaction.Version, true) {
+		return
+	}
+
+	// PUT replaces the whole transaction, so it takes the same input as CreateTransaction
+	var input transactionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := transaction
+	loc := userLocation(userID.(uint))
+	if err := input.apply(&transaction, loc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	transaction.Version++
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := setTransactionPayee(tx, &transaction, input.Payee); err != nil {
+			return err
+		}
+		if err := saveIfVersion(tx, &transaction, before.Version); err != nil {
+			return err
+		}
+		if err := retrainClassifier(tx, &before, &transaction); err != nil {
+			return err
+		}
+		if err := refreshPercentBudgetsForEdit(tx, c, &before, &transaction, loc); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionUpdate, AuditEntityTransaction, transaction.ID, before, transaction)
+	})
+	if errors.Is(err, errVersionConflict) {
+		preconditionFailed(c)
+		return
+	}
+	if errors.Is(err, errPayeeNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Payee not found")})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update transaction")})
+		return
+	}
+
+	flagAnomalies([]*Transaction{&transaction})
+	DB.Preload("Category").Preload("Tags").Preload("Payee").Preload("Anomalies").First(&transaction, transaction.ID)
+
+	setETag(c, transaction.Version)
+	c.JSON(http.StatusOK, transaction)
+}
+
+// PatchTransaction partially updates a transaction: absent fields are kept and null clears optional ones
+func PatchTransaction(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
 	}
 
+	var transaction Transaction
+	if err := DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&transaction).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Transaction not found")})
+		return
+	}
+
+	if !checkIfMatch(c, transaction.Version, true) {
+		return
+	}
+
+	var input transactionPatch
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	transaction.Type = input.Type
-	transaction.Amount = input.Amount
-	transaction.Currency = input.Currency
-	transaction.ExchangeRate = input.ExchangeRate
-	transaction.Note = input.Note
-	transaction.CategoryID = &input.CategoryID
+	before := transaction
+	loc := userLocation(userID.(uint))
+	if err := input.apply(&transaction, loc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	transaction.Version++
 
-	if err := DB.Save(&transaction).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transaction"})
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := setTransactionPayee(tx, &transaction, input.Payee.Value); err != nil {
+			return err
+		}
+		if err := saveIfVersion(tx, &transaction, before.Version); err != nil {
+			return err
+		}
+		if err := retrainClassifier(tx, &before, &transaction); err != nil {
+			return err
+		}
+		if err := refreshPercentBudgetsForEdit(tx, c, &before, &transaction, loc); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionUpdate, AuditEntityTransaction, transaction.ID, before, transaction)
+	})
+	if errors.Is(err, errVersionConflict) {
+		preconditionFailed(c)
+		return
+	}
+	if errors.Is(err, errPayeeNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Payee not found")})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update transaction")})
 		return
 	}
 
-	DB.Preload("Category").First(&transaction, transaction.ID)
+	flagAnomalies([]*Transaction{&transaction})
+	DB.Preload("Category").Preload("Tags").Preload("Payee").Preload("Anomalies").First(&transaction, transaction.ID)
 
+	setETag(c, transaction.Version)
 	c.JSON(http.StatusOK, transaction)
 }
 
@@ -147,55 +267,113 @@ func UpdateTransaction(c *gin.Context) {
 func SoftDeleteTransaction(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
 		return
 	}
 
 	var transaction Transaction
 	if err := DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&transaction).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Transaction not found")})
 		return
 	}
 
+	if !checkIfMatch(c, transaction.Version, true) {
+		return
+	}
+
+	before := transaction
 	now := time.Now()
-	if err := DB.Model(&transaction).Update("deleted_at", now).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete transaction"})
+	loc := userLocation(userID.(uint))
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := updateIfVersion(tx, &transaction, before.Version, map[string]interface{}{"deleted_at": now, "version": transaction.Version + 1}); err != nil {
+			return err
+		}
+		transaction.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+		transaction.Version++
+		if err := refreshPercentBudgets(tx, c, &transaction, loc); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionDelete, AuditEntityTransaction, transaction.ID, before, transaction)
+	})
+	if errors.Is(err, errVersionConflict) {
+		preconditionFailed(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to delete transaction")})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Transaction deleted", "deleted_at": now})
+	setETag(c, transaction.Version)
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Transaction deleted"), "deleted_at": now})
 }
 
 // Restore a soft-deleted transaction
 func RestoreTransaction(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
 		return
 	}
 
 	var transaction Transaction
 	if err := DB.Unscoped().Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&transaction).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Transaction not found")})
+		return
+	}
+
+	// Deleted transactions cannot be fetched, so If-Match is checked only when sent
+	if !checkIfMatch(c, transaction.Version, false) {
 		return
 	}
 
-	if err := DB.Unscoped().Model(&transaction).Update("deleted_at", nil).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore transaction"})
+	before := transaction
+	loc := userLocation(userID.(uint))
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := updateIfVersion(tx, &transaction, before.Version, map[string]interface{}{"deleted_at": nil, "version": transaction.Version + 1}); err != nil {
+			return err
+		}
+		transaction.DeletedAt = gorm.DeletedAt{}
+		transaction.Version++
+		if err := refreshPercentBudgets(tx, c, &transaction, loc); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionRestore, AuditEntityTransaction, transaction.ID, before, transaction)
+	})
+	if errors.Is(err, errVersionConflict) {
+		preconditionFailed(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to restore transaction")})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Transaction restored"})
+	setETag(c, transaction.Version)
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Transaction restored")})
 }
 
-// CreateCategory handles adding a new category
+// CreateCategory handles adding a new system category (admin only)
 func CreateCategory(c *gin.Context) {
 	var category Category
 	if err := c.ShouldBindJSON(&category); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	DB.Create(&category)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&category).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionCreate, AuditEntityCategory, category.ID, nil, category)
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": T(c, "Category already exists")})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to create category")})
+		return
+	}
 	c.JSON(http.StatusCreated, category)
 }
 
@@ -206,10 +384,16 @@ func GetCategories(c *gin.Context) {
 	c.JSON(http.StatusOK, categories)
 }
 
-// GetTransactionsByCategory retrieves transactions filtered by category
+// GetTransactionsByCategory retrieves the user's transactions in a category
 func GetTransactionsByCategory(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
 	var transactions []Transaction
-	DB.Preload("Category").Where("category_id = ?", c.Param("id")).Find(&transactions)
+	DB.Preload("Category").Where("user_id = ? AND category_id = ? AND deleted_at IS NULL", userID, c.Param("id")).Find(&transactions)
 	c.JSON(http.StatusOK, transactions)
 }
 
@@ -217,7 +401,7 @@ func GetTransactionsByCategory(c *gin.Context) {
 func GetSummary(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
 		return
 	}
 
@@ -232,7 +416,7 @@ func GetSummary(c *gin.Context) {
 		Select("COALESCE(SUM(amount * exchange_rate), 0)").Scan(&totalExpense).Error
 
 	if err1 != nil || err2 != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch summary data"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch summary data")})
 		return
 	}
 
@@ -244,17 +428,19 @@ func GetSummary(c *gin.Context) {
 
 	var trends []MonthlySummary
 
+	// Group by month as seen from the user's timezone rather than the database session's
+	timezone := loadUserSettings(userID.(uint)).Timezone
 	err3 := DB.Model(&Transaction{}).
-		Select("TO_CHAR(created_at, 'YYYY-MM') AS month, "+
+		Select("TO_CHAR(occurred_at AT TIME ZONE ?, 'YYYY-MM') AS month, "+
 			"COALESCE(SUM(CASE WHEN type = 'Income' THEN amount * exchange_rate ELSE 0 END), 0) AS total_income, "+
-			"COALESCE(SUM(CASE WHEN type = 'Expense' THEN amount * exchange_rate ELSE 0 END), 0) AS total_expense").
+			"COALESCE(SUM(CASE WHEN type = 'Expense' THEN amount * exchange_rate ELSE 0 END), 0) AS total_expense", timezone).
 		Where("user_id = ? AND deleted_at IS NULL", userID).
 		Group("month").
 		Order("month ASC").
 		Scan(&trends).Error
 
 	if err3 != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch trend data"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch trend data")})
 		return
 	}
 
@@ -270,31 +456,28 @@ func GetSummary(c *gin.Context) {
 func GetBudgets(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
 		return
 	}
 
+	loc := userLocation(userID.(uint))
+
 	var budgets []Budget
 	if err := DB.Preload("Category").
 		Where("user_id = ?", userID).
 		Find(&budgets).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch budgets"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch budgets")})
 		return
 	}
 
 	for i := range budgets {
-		var totalSpent sql.NullFloat64
-		err := DB.Model(&Transaction{}).
-			Where("user_id = ? AND category_id = ? AND type = ? AND deleted_at IS NULL", userID, budgets[i].CategoryID, "Expense").
-			Select("COALESCE(SUM(amount * exchange_rate), 0)").
-			Scan(&totalSpent).Error
-
+		spent, err := budgetSpent(&budgets[i], loc)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total spent data"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch total spent data")})
 			return
 		}
 
-		budgets[i].Spent = totalSpent.Float64
+		budgets[i].Spent = spent
 	}
 
 	c.JSON(http.StatusOK, budgets)
@@ -304,7 +487,7 @@ func GetBudgets(c *gin.Context) {
 func GetBudgetByID(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
 		return
 	}
 
@@ -312,23 +495,21 @@ func GetBudgetByID(c *gin.Context) {
 	if err := DB.Preload("Category").
 		Where("id = ? AND user_id = ?", c.Param("id"), userID).
 		First(&budget).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Budget not found")})
 		return
 	}
 
-	var totalSpent sql.NullFloat64
-	err := DB.Model(&Transaction{}).
-		Where("user_id = ? AND category_id = ? AND type = ? AND deleted_at IS NULL", userID, budget.CategoryID, "Expense").
-		Select("COALESCE(SUM(amount * exchange_rate), 0)").
-		Scan(&totalSpent).Error
-
+	spent, err := budgetSpent(&budget, userLocation(userID.(uint)))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total spent data"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch total spent data")})
 		return
 	}
 
-	budget.Spent = totalSpent.Float64
+	budget.Spent = spent
 
+	// Spent changes with the transactions but the version does not, so If-None-Match is not
+	// honoured here; the ETag is only for If-Match on later writes
+	setETag(c, budget.Version)
 	c.JSON(http.StatusOK, budget)
 }
 
@@ -336,15 +517,13 @@ func GetBudgetByID(c *gin.Context) {
 func CreateBudget(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
 		return
 	}
 
 	var input struct {
-		CategoryID   uint    `json:"category_id" binding:"required"`
-		Amount       float64 `json:"amount" binding:"required"`
-		Currency     string  `json:"currency" binding:"required"`
-		ExchangeRate float64 `json:"exchange_rate" binding:"required"`
+		CategoryID uint `json:"category_id" binding:"required"`
+		budgetInput
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -352,21 +531,34 @@ func CreateBudget(c *gin.Context) {
 		return
 	}
 
+	// The period defaults to the one containing today in the user's timezone
+	loc := userLocation(userID.(uint))
+	input.defaultPeriod(time.Now().In(loc))
+
 	budget := Budget{
-		UserID:       userID.(uint),
-		CategoryID:   input.CategoryID,
-		Amount:       input.Amount,
-		Currency:     input.Currency,
-		ExchangeRate: input.ExchangeRate,
+		UserID:     userID.(uint),
+		CategoryID: input.CategoryID,
+		Version:    1,
+	}
+	if err := input.apply(&budget, loc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	if err := DB.Create(&budget).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create budget"})
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&budget).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionCreate, AuditEntityBudget, budget.ID, nil, budget)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to create budget")})
 		return
 	}
 
 	DB.Preload("Category").First(&budget, budget.ID)
 
+	setETag(c, budget.Version)
 	c.JSON(http.StatusCreated, budget)
 }
 
@@ -374,33 +566,104 @@ func CreateBudget(c *gin.Context) {
 func UpdateBudget(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
 		return
 	}
 
 	var budget Budget
 	if err := DB.Preload("Category").Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&budget).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Budget not found")})
 		return
 	}
 
-	var input struct {
-		Amount       float64 `json:"amount"`
-		Currency     string  `json:"currency"`
-		ExchangeRate float64 `json:"exchange_rate"`
-		Month        string  `json:"month"`
+	if !checkIfMatch(c, budget.Version, true) {
+		return
 	}
 
+	// PUT replaces the whole budget, so every field is required
+	var input budgetInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if input.Month == "" && input.StartDate == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMonthRequired.Error()})
+		return
+	}
+
+	before := budget
+	if err := input.apply(&budget, userLocation(userID.(uint))); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	budget.Version++
 
-	budget.Amount = input.Amount
-	budget.Currency = input.Currency
-	budget.ExchangeRate = input.ExchangeRate
-	budget.Month = input.Month
-	DB.Save(&budget)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveIfVersion(tx, &budget, before.Version); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionUpdate, AuditEntityBudget, budget.ID, before, budget)
+	})
+	if errors.Is(err, errVersionConflict) {
+		preconditionFailed(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update budget")})
+		return
+	}
+
+	setETag(c, budget.Version)
+	c.JSON(http.StatusOK, budget)
+}
+
+// PatchBudget partially updates a budget, keeping every field that is absent from the body
+func PatchBudget(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var budget Budget
+	if err := DB.Preload("Category").Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&budget).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Budget not found")})
+		return
+	}
+
+	if !checkIfMatch(c, budget.Version, true) {
+		return
+	}
+
+	var input budgetPatch
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := budget
+	if err := input.apply(&budget, userLocation(userID.(uint))); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	budget.Version++
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveIfVersion(tx, &budget, before.Version); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionUpdate, AuditEntityBudget, budget.ID, before, budget)
+	})
+	if errors.Is(err, errVersionConflict) {
+		preconditionFailed(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update budget")})
+		return
+	}
+
+	setETag(c, budget.Version)
 	c.JSON(http.StatusOK, budget)
 }
 
@@ -408,42 +671,117 @@ func UpdateBudget(c *gin.Context) {
 func SoftDeleteBudget(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
 		return
 	}
 
 	var budget Budget
 	if err := DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&budget).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Budget not found")})
+		return
+	}
+
+	if !checkIfMatch(c, budget.Version, true) {
 		return
 	}
 
-	if err := DB.Model(&budget).Update("deleted_at", time.Now()).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete budget"})
+	before := budget
+	now := time.Now()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := updateIfVersion(tx, &budget, before.Version, map[string]interface{}{"deleted_at": now, "version": budget.Version + 1}); err != nil {
+			return err
+		}
+		budget.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+		budget.Version++
+		return recordAudit(tx, c, AuditActionDelete, AuditEntityBudget, budget.ID, before, budget)
+	})
+	if errors.Is(err, errVersionConflict) {
+		preconditionFailed(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to delete budget")})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Budget deleted (soft deleted)"})
+	setETag(c, budget.Version)
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Budget deleted (soft deleted)")})
 }
 
 // RestoreBudget restores a soft deleted budget
 func RestoreBudget(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
 		return
 	}
 
 	var budget Budget
 	if err := DB.Unscoped().Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&budget).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Budget not found")})
+		return
+	}
+
+	// Deleted budgets cannot be fetched, so If-Match is checked only when sent
+	if !checkIfMatch(c, budget.Version, false) {
 		return
 	}
 
-	if err := DB.Model(&budget).Update("deleted_at", nil).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore budget"})
+	before := budget
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := updateIfVersion(tx, &budget, before.Version, map[string]interface{}{"deleted_at": nil, "version": budget.Version + 1}); err != nil {
+			return err
+		}
+		budget.DeletedAt = gorm.DeletedAt{}
+		budget.Version++
+		return recordAudit(tx, c, AuditActionRestore, AuditEntityBudget, budget.ID, before, budget)
+	})
+	if errors.Is(err, errVersionConflict) {
+		preconditionFailed(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to restore budget")})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Budget restored"})
+	setETag(c, budget.Version)
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Budget restored")})
+}
+
+// occurredAtLayouts lists the accepted transaction date formats, from most to least specific
+var occurredAtLayouts = []string{
+	time.RFC3339,          // Date, time and timezone offset
+	"2006-01-02T15:04:05", // Date and time
+	"2006-01-02T15:04",    // Date and time without seconds
+	"2006-01-02",          // Date only
+}
+
+// parseOccurredAt parses a transaction date with an optional time and timezone.
+// Values without an explicit offset are interpreted in loc.
+func parseOccurredAt(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range occurredAtLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid occurred_at %q, expected YYYY-MM-DD or RFC 3339", value)
+}
+
+// monthRange returns the [start, end) window of a "YYYY-MM" month in loc
+func monthRange(month string, loc *time.Location) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01", month, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid month %q, expected YYYY-MM", month)
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// budgetSpent sums the expenses (in IDR) that fall inside the budget's period, evaluated in loc
+func budgetSpent(budget *Budget, loc *time.Location) (float64, error) {
+	start, end, err := budgetWindow(budget, loc)
+	if err != nil {
+		return 0, err
+	}
+	return categorySpent(budget.UserID, budget.CategoryID, start, end)
 }
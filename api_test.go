@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetTransactionsByCategoryOnlyListsOwnTransactions(t *testing.T) {
+	setupTestDB(t, &Category{}, &Transaction{})
+
+	category := Category{Name: "Food"}
+	if err := DB.Create(&category).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, userID := range []uint{1, 2} {
+		transaction := Transaction{UserID: userID, CategoryID: &category.ID, Type: "Expense", Amount: 10000, Currency: "IDR", ExchangeRate: 1, OccurredAt: time.Now()}
+		if err := DB.Create(&transaction).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/categories/:id/transactions", func(c *gin.Context) { c.Set("userID", uint(1)) }, GetTransactionsByCategory)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/categories/1/transactions", nil))
+
+	var transactions []Transaction
+	if err := json.Unmarshal(response.Body.Bytes(), &transactions); err != nil {
+		t.Fatalf("status %d, body %s: %v", response.Code, response.Body.String(), err)
+	}
+	if len(transactions) != 1 || transactions[0].UserID != 1 {
+		t.Fatalf("got %+v, want only user 1's transaction", transactions)
+	}
+}
+
+func TestGetBudgetByIDIgnoresIfNoneMatch(t *testing.T) {
+	setupTestDB(t, &Category{}, &Budget{}, &Transaction{}, &UserSettings{})
+
+	category := Category{Name: "Food"}
+	if err := DB.Create(&category).Error; err != nil {
+		t.Fatal(err)
+	}
+	budget := Budget{UserID: 1, CategoryID: category.ID, Amount: 100000, Currency: "IDR", ExchangeRate: 1, Month: "2024-03", PeriodType: BudgetPeriodMonthly, StartDate: "2024-03-01", EndDate: "2024-03-31", Version: 1}
+	if err := DB.Create(&budget).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/budgets/:id", func(c *gin.Context) { c.Set("userID", uint(1)) }, GetBudgetByID)
+	get := func() (int, Budget) {
+		request := httptest.NewRequest(http.MethodGet, "/budgets/1", nil)
+		request.Header.Set("If-None-Match", entityETag(budget.Version))
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		var got Budget
+		json.Unmarshal(response.Body.Bytes(), &got)
+		if response.Header().Get("ETag") != entityETag(budget.Version) {
+			t.Errorf("ETag %q, want %q", response.Header().Get("ETag"), entityETag(budget.Version))
+		}
+		return response.Code, got
+	}
+
+	if code, got := get(); code != http.StatusOK || got.Spent != 0 {
+		t.Fatalf("status %d, spent %v; want 200 and nothing spent", code, got.Spent)
+	}
+
+	// Spending in the budget's month changes Spent without a new budget version
+	expense := Transaction{UserID: 1, CategoryID: &category.ID, Type: "Expense", Amount: 25000, Currency: "IDR", ExchangeRate: 1, OccurredAt: time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)}
+	if err := DB.Create(&expense).Error; err != nil {
+		t.Fatal(err)
+	}
+	if code, got := get(); code != http.StatusOK || got.Spent != 25000 {
+		t.Fatalf("status %d, spent %v; want 200 with the new spending", code, got.Spent)
+	}
+}
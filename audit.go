@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Audit actions
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionDisable = "disable"
+	AuditActionPurge   = "purge"
+
+	AuditActionChangePassword = "change_password"
+)
+
+// Audited entity types
+const (
+	AuditEntityTransaction  = "transaction"
+	AuditEntityBudget       = "budget"
+	AuditEntityCategory     = "category"
+	AuditEntityExchangeRate = "exchange_rate"
+	AuditEntityUser         = "user"
+	AuditEntitySettings     = "user_settings"
+	AuditEntityToken        = "personal_access_token"
+)
+
+// auditOmittedFields are left out of snapshots: preloaded associations and calculated values
+var auditOmittedFields = []string{"category", "tags", "payee", "anomalies", "spent"}
+
+// auditSnapshot converts an entity into the JSON object stored in the audit log
+func auditSnapshot(entity interface{}) (map[string]interface{}, error) {
+	if entity == nil || reflect.ValueOf(entity).IsZero() {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	for _, field := range auditOmittedFields {
+		delete(snapshot, field)
+	}
+	return snapshot, nil
+}
+
+// auditDiff lists the fields whose values differ between two snapshots
+func auditDiff(before, after map[string]interface{}) map[string]interface{} {
+	changes := make(map[string]interface{})
+	for field, afterValue := range after {
+		if field == "updated_at" {
+			continue
+		}
+		if beforeValue, ok := before[field]; !ok || !reflect.DeepEqual(beforeValue, afterValue) {
+			changes[field] = gin.H{"before": before[field], "after": afterValue}
+		}
+	}
+	for field, beforeValue := range before {
+		if _, ok := after[field]; !ok {
+			changes[field] = gin.H{"before": beforeValue, "after": nil}
+		}
+	}
+	return changes
+}
+
+// marshalJSON encodes v for a jsonb column, returning nil (SQL NULL) for nil values
+func marshalJSON(v interface{}) (json.RawMessage, error) {
+	if v == nil || reflect.ValueOf(v).IsNil() {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// recordAudit appends an audit entry describing a change. Pass the same tx as the change itself so
+// the entry is written atomically with it; before is nil for creations and after is nil for purges.
+// Background jobs pass a nil c, which records the change without an actor.
+func recordAudit(tx *gorm.DB, c *gin.Context, action, entityType string, entityID interface{}, before, after interface{}) error {
+	beforeSnapshot, err := auditSnapshot(before)
+	if err != nil {
+		return err
+	}
+	afterSnapshot, err := auditSnapshot(after)
+	if err != nil {
+		return err
+	}
+
+	entry := AuditLog{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   fmt.Sprint(entityID),
+	}
+	if c != nil {
+		entry.IP = c.ClientIP()
+		entry.UserAgent = c.Request.UserAgent()
+		if actorID, exists := c.Get("userID"); exists {
+			entry.ActorID = actorID.(uint)
+		}
+	}
+
+	// Versioned entities (transactions, budgets) record which version the change produced
+	if version, ok := afterSnapshot["version"].(float64); ok {
+		entry.Version = uint(version)
+	}
+
+	if entry.Before, err = marshalJSON(beforeSnapshot); err != nil {
+		return err
+	}
+	if entry.After, err = marshalJSON(afterSnapshot); err != nil {
+		return err
+	}
+	if entry.Changes, err = marshalJSON(auditDiff(beforeSnapshot, afterSnapshot)); err != nil {
+		return err
+	}
+
+	return tx.Create(&entry).Error
+}
+
+// auditQuery applies the entity, action and date filters shared by the audit endpoints
+func auditQuery(c *gin.Context, query *gorm.DB, loc *time.Location) (*gorm.DB, error) {
+	if entityType := c.Query("entity_type"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityID := c.Query("entity_id"); entityID != "" {
+		query = query.Where("entity_id = ?", entityID)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if from := c.Query("from"); from != "" {
+		start, err := time.ParseInLocation("2006-01-02", from, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from date %q, expected YYYY-MM-DD", from)
+		}
+		query = query.Where("created_at >= ?", start)
+	}
+	if to := c.Query("to"); to != "" {
+		end, err := time.ParseInLocation("2006-01-02", to, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", to)
+		}
+		// The end date is inclusive
+		query = query.Where("created_at < ?", end.AddDate(0, 0, 1))
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 500 {
+		limit = 100
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if offset < 0 {
+		offset = 0
+	}
+	return query.Order("created_at DESC, id DESC").Limit(limit).Offset(offset), nil
+}
+
+// GetAuditLog lists the changes made by the authenticated user, filterable by entity and date
+func GetAuditLog(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	query, err := auditQuery(c, DB.Where("actor_id = ?", userID), userLocation(userID.(uint)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var entries []AuditLog
+	if err := query.Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch audit log")})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// AdminGetAuditLog lists changes made by any user, optionally filtered by actor
+func AdminGetAuditLog(c *gin.Context) {
+	query := DB.Model(&AuditLog{})
+	if actorID := c.Query("actor_id"); actorID != "" {
+		query = query.Where("actor_id = ?", actorID)
+	}
+
+	query, err := auditQuery(c, query, time.UTC)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var entries []AuditLog
+	if err := query.Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch audit log")})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
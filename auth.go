@@ -1,41 +1,88 @@
 package main
 
 import (
-	"fmt"
+	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
-// Secret key used for signing JWT tokens
-var secretKey = getSecretKey()
-
-// getSecretKey retrieves the secret key from environment variables or sets a default value
-func getSecretKey() string {
-	key := os.Getenv("JWT_SECRET")
-	if key == "" {
-		key = "default_secret_key" // Use a default key if JWT_SECRET is not set
-	}
-	return key
-}
-
 // AuthRequest represents the request body for authentication (login)
 type AuthRequest struct {
 	Email    string `json:"email" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
 
+// Account lockout policy for repeated failed logins
+const (
+	maxFailedLogins = 5
+	lockoutDuration = 15 * time.Minute
+)
+
+// dummyPasswordHash is checked when the email is unknown, so that both failure paths take as long
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("gobudget-dummy-password"), bcrypt.DefaultCost)
+
+// isLockedOut reports whether the account is temporarily locked after failed logins
+func isLockedOut(user *User) bool {
+	return user.LockedUntil != nil && time.Now().Before(*user.LockedUntil)
+}
+
+// recordFailedLogin counts a failed attempt and locks the account once the limit is reached. The
+// count and the lock are one conditional UPDATE so that concurrent attempts cannot skip the lock.
+func recordFailedLogin(user *User) {
+	DB.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"failed_logins": gorm.Expr("CASE WHEN failed_logins + 1 >= ? THEN 0 ELSE failed_logins + 1 END", maxFailedLogins),
+		"locked_until":  gorm.Expr("CASE WHEN failed_logins + 1 >= ? THEN ? ELSE locked_until END", maxFailedLogins, time.Now().Add(lockoutDuration)),
+	})
+}
+
+// resetFailedLogins clears the failure counter after a successful login
+func resetFailedLogins(user *User) {
+	if user.FailedLogins == 0 && user.LockedUntil == nil {
+		return
+	}
+	DB.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{"failed_logins": 0, "locked_until": nil})
+}
+
+// signToken signs a set of claims as a JWT with the active key
+func signToken(claims jwt.MapClaims) (string, error) {
+	return AppKeys.Sign(claims)
+}
+
 // generateToken creates a JWT token with user ID and expiration time
 func generateToken(userID uint) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	return signToken(jwt.MapClaims{
 		"user_id": userID,
 		"exp":     time.Now().Add(time.Hour * 24).Unix(), // Token expires in 24 hours
 	})
-	return token.SignedString([]byte(secretKey))
+}
+
+// generateChallengeToken creates a short-lived JWT proving only that the password step of a
+// two-factor login succeeded; AuthMiddleware refuses it as a session token
+func generateChallengeToken(userID uint) (string, error) {
+	return signToken(jwt.MapClaims{
+		"user_id": userID,
+		"purpose": "2fa",
+		"exp":     time.Now().Add(5 * time.Minute).Unix(), // The second step must follow promptly
+	})
+}
+
+// setAuthCookie stores the session JWT in an HTTP-only cookie
+func setAuthCookie(c *gin.Context, token string) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:        "token",
+		Value:       token,
+		Path:        "/",
+		Domain:      "gobudget.my.id",
+		HttpOnly:    true,
+		Secure:      true,
+		SameSite:    http.SameSiteNoneMode,
+		Partitioned: true,
+	})
 }
 
 // Register handles user registration
@@ -55,7 +102,7 @@ func Register(c *gin.Context) {
 	// Hash the user's password before storing it
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to hash password")})
 		return
 	}
 
@@ -67,12 +114,23 @@ func Register(c *gin.Context) {
 	}
 
 	// Save user to database
-	if err := DB.Create(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionCreate, AuditEntityUser, user.ID, nil, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to create user")})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully"})
+	// Ask the user to confirm their address; registration still succeeds if the email fails
+	if err := sendVerificationEmail(&user); err != nil {
+		log.Println("Failed to send verification email:", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": T(c, "User registered successfully")})
 }
 
 // Login handles user authentication
@@ -81,82 +139,100 @@ func Login(c *gin.Context) {
 
 	// Validate request body
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Invalid request")})
 		return
 	}
 
-	// Find user by email
+	// Find user by email; an unknown email gets the same response as a wrong password
 	var user User
 	if err := DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials - email not found"})
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(input.Password))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid credentials")})
 		return
 	}
 
-	// Check if the password is correct
+	// Check if the password is correct; a locked account fails the same way as a wrong password
+	// so that the response does not reveal the lock
 	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password))
+	if isLockedOut(&user) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid credentials")})
+		return
+	}
 	if err != nil {
-		fmt.Println("Password mismatch!")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials - password mismatch"})
+		recordFailedLogin(&user)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid credentials")})
+		return
+	}
+
+	// The password was right, so earlier typos no longer count towards the lockout, even if the
+	// user never completes a second step
+	resetFailedLogins(&user)
+
+	// Disabled accounts cannot log in
+	if user.DisabledAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": T(c, "Account disabled")})
+		return
+	}
+
+	// Optionally refuse accounts that have not confirmed their email address
+	if requireEmailVerification() && user.EmailVerifiedAt == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": T(c, "Email address not verified")})
+		return
+	}
+
+	// Users with two-factor authentication must pass a second step before getting a session
+	if user.TOTPEnabled {
+		challenge, err := generateChallengeToken(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to generate token")})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"two_factor_required": true, "challenge_token": challenge})
 		return
 	}
 
 	// Generate JWT token
 	token, err := generateToken(user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to generate token")})
 		return
 	}
 
 	// Set JWT token in HTTP-only cookie
-	http.SetCookie(c.Writer, &http.Cookie{
-		Name:        "token",
-		Value:       token,
-		Path:        "/",
-		Domain:      "gobudget.my.id",
-		HttpOnly:    true,
-		Secure:      true,
-		SameSite:    http.SameSiteNoneMode,
-		Partitioned: true,
-	})
+	setAuthCookie(c, token)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Login successful"})
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Login successful")})
 }
 
 // Logout clears the JWT token from the cookie
 func Logout(c *gin.Context) {
-	http.SetCookie(c.Writer, &http.Cookie{
-		Name:        "token",
-		Value:       "",
-		Path:        "/",
-		Domain:      "gobudget.my.id",
-		HttpOnly:    true,
-		Secure:      true,
-		SameSite:    http.SameSiteNoneMode,
-		Partitioned: true,
-	})
+	setAuthCookie(c, "")
 
-	c.JSON(http.StatusOK, gin.H{"message": "Logout successful"})
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Logout successful")})
 }
 
 // GetUser retrieves the logged-in user's details
 func GetUser(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
 		return
 	}
 
 	// Fetch user details from database
 	var user User
 	if err := DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "User not found")})
 		return
 	}
 
 	// Return user details
 	c.JSON(http.StatusOK, gin.H{
-		"id":    user.ID,
-		"email": user.Email,
-		"name":  user.Name,
+		"id":             user.ID,
+		"email":          user.Email,
+		"name":           user.Name,
+		"email_verified": user.EmailVerifiedAt != nil,
+		"totp_enabled":   user.TOTPEnabled,
+		"role":           user.Role,
 	})
 }
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRecordFailedLoginLocksAtTheLimit(t *testing.T) {
+	setupTestDB(t, &User{})
+
+	user := User{Name: "Test", Email: "test@example.com", Password: "x"}
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= maxFailedLogins; i++ {
+		recordFailedLogin(&user)
+		if err := DB.First(&user, user.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if i < maxFailedLogins && (isLockedOut(&user) || user.FailedLogins != i) {
+			t.Fatalf("after %d failures: failed_logins = %d, locked = %v", i, user.FailedLogins, isLockedOut(&user))
+		}
+	}
+	if !isLockedOut(&user) || user.FailedLogins != 0 {
+		t.Fatalf("after %d failures: failed_logins = %d, locked = %v, want a lock", maxFailedLogins, user.FailedLogins, isLockedOut(&user))
+	}
+
+	// Further failures while locked keep the lock in place
+	recordFailedLogin(&user)
+	if err := DB.First(&user, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !isLockedOut(&user) {
+		t.Fatal("a failure during the lock cleared it")
+	}
+}
+
+func TestLoginClearsFailuresBeforeTheSecondStep(t *testing.T) {
+	setupTestDB(t, &User{})
+	useTestKeys(t)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	user := User{Name: "Test", Email: "test@example.com", Password: string(hash), EmailVerifiedAt: &now, TOTPEnabled: true, FailedLogins: maxFailedLogins - 1}
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/login", Login)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"test@example.com","password":"correct horse"}`)))
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), "challenge_token") {
+		t.Fatalf("status %d, body %s; want a two-factor challenge", response.Code, response.Body.String())
+	}
+
+	if err := DB.First(&user, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.FailedLogins != 0 {
+		t.Errorf("failed_logins = %d after the right password, want 0", user.FailedLogins)
+	}
+}
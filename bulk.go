@@ -0,0 +1,465 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxBulkItems caps how many transactions a single bulk request may touch
+const maxBulkItems = 500
+
+// maxTagLength matches the size of Tag.Name
+const maxTagLength = 50
+
+// Bulk operations on individual transactions
+const (
+	BulkOpCreate  = "create"
+	BulkOpUpdate  = "update"
+	BulkOpDelete  = "delete"
+	BulkOpRestore = "restore"
+)
+
+// Bulk actions applied to every transaction matching a filter
+const (
+	BulkActionSetCategory = "set_category"
+	BulkActionAddTag      = "add_tag"
+	BulkActionDelete      = "delete"
+)
+
+// bulkOperation is one entry of a bulk request's operation list
+type bulkOperation struct {
+	Op      string          `json:"op"`
+	ID      uint            `json:"id"`      // Target of update, delete and restore
+	Version uint            `json:"version"` // When set, the operation fails if the transaction has moved past it
+	Data    json.RawMessage `json:"data"`    // transactionInput for create, transactionPatch for update
+}
+
+// bulkFilter selects the active transactions a bulk action applies to
+type bulkFilter struct {
+	IDs          []uint         `json:"ids"`
+	Type         string         `json:"type"`
+	CategoryID   Optional[uint] `json:"category_id"` // Null matches uncategorized transactions
+	From         string         `json:"from"`        // YYYY-MM-DD in the user's timezone
+	To           string         `json:"to"`          // YYYY-MM-DD, inclusive
+	NoteContains string         `json:"note_contains"`
+}
+
+// bulkAction is the change made to every transaction matched by a bulkFilter
+type bulkAction struct {
+	Type       string `json:"type"`
+	CategoryID *uint  `json:"category_id"` // For set_category; null removes the category
+	Tag        string `json:"tag"`         // For add_tag
+}
+
+// bulkResult reports the outcome of one operation, or of the action on one matched transaction
+type bulkResult struct {
+	Index       int          `json:"index"`
+	Op          string       `json:"op"`
+	ID          uint         `json:"id,omitempty"`
+	Status      int          `json:"status"` // HTTP status the equivalent single request would have returned
+	Error       string       `json:"error,omitempty"`
+	Transaction *Transaction `json:"transaction,omitempty"`
+}
+
+// bulkItemError is an expected failure of one bulk item, reported with its own status
+type bulkItemError struct {
+	status  int
+	message string
+}
+
+func (e *bulkItemError) Error() string {
+	return e.message
+}
+
+// BulkTransactions applies a list of operations, or one action to every transaction matching a
+// filter, in a single database transaction. Either everything is applied or nothing is, and the
+// response lists the result of each item.
+func BulkTransactions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var input struct {
+		Operations []bulkOperation `json:"operations"`
+		Filter     *bulkFilter     `json:"filter"`
+		Action     *bulkAction     `json:"action"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	byFilter := input.Filter != nil || input.Action != nil
+	if byFilter == (len(input.Operations) > 0) || (byFilter && (input.Filter == nil || input.Action == nil)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Provide either operations or a filter and an action")})
+		return
+	}
+	if len(input.Operations) > maxBulkItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Too many items in one bulk request")})
+		return
+	}
+
+	loc := userLocation(userID.(uint))
+	var results []bulkResult
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if byFilter {
+			var err error
+			results, err = applyBulkAction(tx, c, userID.(uint), loc, input.Filter, input.Action)
+			return err
+		}
+
+		for i, op := range input.Operations {
+			transaction, status, err := applyBulkOperation(tx, c, userID.(uint), loc, op)
+			results = append(results, bulkResult{Index: i, Op: op.Op, ID: op.ID, Status: status, Transaction: transaction})
+			if err != nil {
+				failBulkResult(c, &results[i], err)
+				for j, pending := range input.Operations[i+1:] {
+					results = append(results, skippedBulkResult(c, i+1+j, pending.Op, pending.ID))
+				}
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		status, message := http.StatusInternalServerError, T(c, "Failed to update transaction")
+		var itemErr *bulkItemError
+		if errors.As(err, &itemErr) {
+			status, message = itemErr.status, itemErr.message
+		}
+		// Results are empty when the filter or action itself was rejected
+		if len(results) == 0 {
+			c.JSON(status, gin.H{"error": message})
+			return
+		}
+		// Nothing was committed, so earlier items report what they would have returned
+		c.JSON(status, gin.H{"error": T(c, "Bulk request failed, no changes were applied"), "committed": false, "results": results})
+		return
+	}
+
+	// Created and edited transactions are checked again now that the changes are committed
+	var changed []*Transaction
+	for _, result := range results {
+		switch result.Op {
+		case BulkOpCreate, BulkOpUpdate, BulkActionSetCategory:
+			if result.Transaction != nil {
+				changed = append(changed, result.Transaction)
+			}
+		}
+	}
+	flagAnomalies(changed)
+
+	c.JSON(http.StatusOK, gin.H{"committed": true, "results": results})
+}
+
+// failBulkResult records why an item failed
+func failBulkResult(c *gin.Context, result *bulkResult, err error) {
+	result.Transaction = nil
+	result.Status, result.Error = http.StatusInternalServerError, T(c, "Failed to update transaction")
+	var itemErr *bulkItemError
+	if errors.As(err, &itemErr) {
+		result.Status, result.Error = itemErr.status, itemErr.message
+	}
+}
+
+// skippedBulkResult reports an item that was not attempted because an earlier one failed
+func skippedBulkResult(c *gin.Context, index int, op string, id uint) bulkResult {
+	return bulkResult{Index: index, Op: op, ID: id, Status: http.StatusFailedDependency, Error: T(c, "Not applied because an earlier item failed")}
+}
+
+// applyBulkOperation runs one operation of a bulk request and returns the resulting transaction
+func applyBulkOperation(tx *gorm.DB, c *gin.Context, userID uint, loc *time.Location, op bulkOperation) (*Transaction, int, error) {
+	if op.Op == BulkOpCreate {
+		var input transactionInput
+		if err := json.Unmarshal(op.Data, &input); err != nil {
+			return nil, 0, &bulkItemError{http.StatusBadRequest, T(c, "Invalid request")}
+		}
+		transaction := Transaction{UserID: userID, OccurredAt: time.Now(), Version: 1}
+		if err := input.apply(&transaction, loc); err != nil {
+			return nil, 0, &bulkItemError{http.StatusBadRequest, err.Error()}
+		}
+		if err := checkBulkCategory(tx, c, transaction.CategoryID); err != nil {
+			return nil, 0, err
+		}
+		if err := setTransactionPayee(tx, &transaction, input.Payee); err != nil {
+			return nil, 0, bulkPayeeError(c, err)
+		}
+		// Bulk creates are how imports arrive, so the user's rules apply just as for single creates
+		tags, err := applyRulesOnCreate(tx, &transaction)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := tx.Create(&transaction).Error; err != nil {
+			return nil, 0, err
+		}
+		if err := attachTags(tx, c, &transaction, tags); err != nil {
+			return nil, 0, err
+		}
+		if err := trainClassifier(tx, &transaction); err != nil {
+			return nil, 0, err
+		}
+		if err := refreshPercentBudgets(tx, c, &transaction, loc); err != nil {
+			return nil, 0, err
+		}
+		if err := recordAudit(tx, c, AuditActionCreate, AuditEntityTransaction, transaction.ID, nil, transaction); err != nil {
+			return nil, 0, err
+		}
+		return &transaction, http.StatusCreated, nil
+	}
+
+	var transaction Transaction
+	query := tx.Where("id = ? AND user_id = ?", op.ID, userID)
+	if op.Op == BulkOpRestore {
+		query = query.Unscoped()
+	}
+	if err := query.First(&transaction).Error; err != nil {
+		return nil, 0, &bulkItemError{http.StatusNotFound, T(c, "Transaction not found")}
+	}
+	if op.Version != 0 && op.Version != transaction.Version {
+		return nil, 0, &bulkItemError{http.StatusPreconditionFailed, T(c, "Resource was modified by another request")}
+	}
+
+	var err error
+	switch op.Op {
+	case BulkOpUpdate:
+		var patch transactionPatch
+		if err := json.Unmarshal(op.Data, &patch); err != nil {
+			return nil, 0, &bulkItemError{http.StatusBadRequest, T(c, "Invalid request")}
+		}
+		before := transaction
+		if err := patch.apply(&transaction, loc); err != nil {
+			return nil, 0, &bulkItemError{http.StatusBadRequest, err.Error()}
+		}
+		if err := checkBulkCategory(tx, c, transaction.CategoryID); err != nil {
+			return nil, 0, err
+		}
+		if err := setTransactionPayee(tx, &transaction, patch.Payee.Value); err != nil {
+			return nil, 0, bulkPayeeError(c, err)
+		}
+		transaction.Version++
+		if err = saveIfVersion(tx, &transaction, before.Version); err == nil {
+			err = retrainClassifier(tx, &before, &transaction)
+		}
+		if err == nil {
+			err = refreshPercentBudgetsForEdit(tx, c, &before, &transaction, loc)
+		}
+		if err == nil {
+			err = recordAudit(tx, c, AuditActionUpdate, AuditEntityTransaction, transaction.ID, before, transaction)
+		}
+	case BulkOpDelete:
+		err = softDeleteInBulk(tx, c, &transaction, loc)
+	case BulkOpRestore:
+		if !transaction.DeletedAt.Valid {
+			return &transaction, http.StatusOK, nil
+		}
+		before := transaction
+		if err = updateIfVersion(tx, &transaction, before.Version, map[string]interface{}{"deleted_at": nil, "version": before.Version + 1}); err == nil {
+			transaction.DeletedAt = gorm.DeletedAt{}
+			transaction.Version++
+			err = refreshPercentBudgets(tx, c, &transaction, loc)
+		}
+		if err == nil {
+			err = recordAudit(tx, c, AuditActionRestore, AuditEntityTransaction, transaction.ID, before, transaction)
+		}
+	default:
+		return nil, 0, &bulkItemError{http.StatusBadRequest, T(c, "Unknown bulk operation")}
+	}
+
+	if errors.Is(err, errVersionConflict) {
+		return nil, 0, &bulkItemError{http.StatusPreconditionFailed, T(c, "Resource was modified by another request")}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return &transaction, http.StatusOK, nil
+}
+
+// applyBulkAction applies a filter's action to every matching active transaction
+func applyBulkAction(tx *gorm.DB, c *gin.Context, userID uint, loc *time.Location, filter *bulkFilter, action *bulkAction) ([]bulkResult, error) {
+	query, err := bulkFilterQuery(tx, userID, loc, filter)
+	if err != nil {
+		return nil, &bulkItemError{http.StatusBadRequest, err.Error()}
+	}
+
+	var tag Tag
+	switch action.Type {
+	case BulkActionSetCategory:
+		if action.CategoryID != nil && *action.CategoryID == 0 {
+			action.CategoryID = nil
+		}
+		if err := checkBulkCategory(tx, c, action.CategoryID); err != nil {
+			return nil, err
+		}
+	case BulkActionAddTag:
+		if tag, err = findOrCreateTag(tx, c, userID, action.Tag); err != nil {
+			return nil, err
+		}
+	case BulkActionDelete:
+	default:
+		return nil, &bulkItemError{http.StatusBadRequest, T(c, "Unknown bulk action")}
+	}
+
+	var transactions []Transaction
+	if err := query.Preload("Tags").Order("occurred_at DESC").Limit(maxBulkItems + 1).Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+	if len(transactions) > maxBulkItems {
+		return nil, &bulkItemError{http.StatusBadRequest, T(c, "Too many items in one bulk request")}
+	}
+
+	results := make([]bulkResult, 0, len(transactions))
+	for i := range transactions {
+		transaction := &transactions[i]
+		before := *transaction
+		results = append(results, bulkResult{Index: i, Op: action.Type, ID: transaction.ID, Status: http.StatusOK, Transaction: transaction})
+
+		switch action.Type {
+		case BulkActionSetCategory:
+			transaction.CategoryID = action.CategoryID
+			transaction.Version++
+			if err = saveIfVersion(tx, transaction, before.Version); err == nil {
+				err = retrainClassifier(tx, &before, transaction)
+			}
+			if err == nil {
+				err = recordAudit(tx, c, AuditActionUpdate, AuditEntityTransaction, transaction.ID, before, transaction)
+			}
+		case BulkActionAddTag:
+			err = addTagInBulk(tx, c, transaction, tag)
+		case BulkActionDelete:
+			err = softDeleteInBulk(tx, c, transaction, loc)
+		}
+
+		if errors.Is(err, errVersionConflict) {
+			err = &bulkItemError{http.StatusPreconditionFailed, T(c, "Resource was modified by another request")}
+		}
+		if err != nil {
+			failBulkResult(c, &results[i], err)
+			for j := i + 1; j < len(transactions); j++ {
+				results = append(results, skippedBulkResult(c, j, action.Type, transactions[j].ID))
+			}
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// bulkFilterQuery builds the query for the active transactions a bulk filter selects
+func bulkFilterQuery(tx *gorm.DB, userID uint, loc *time.Location, filter *bulkFilter) (*gorm.DB, error) {
+	query := tx.Where("user_id = ?", userID)
+	if len(filter.IDs) > 0 {
+		query = query.Where("id IN ?", filter.IDs)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.CategoryID.Set {
+		if filter.CategoryID.Null {
+			query = query.Where("category_id IS NULL")
+		} else {
+			query = query.Where("category_id = ?", filter.CategoryID.Value)
+		}
+	}
+	if filter.From != "" {
+		from, err := time.ParseInLocation("2006-01-02", filter.From, loc)
+		if err != nil {
+			return nil, errors.New("invalid from date, expected YYYY-MM-DD")
+		}
+		query = query.Where("occurred_at >= ?", from)
+	}
+	if filter.To != "" {
+		to, err := time.ParseInLocation("2006-01-02", filter.To, loc)
+		if err != nil {
+			return nil, errors.New("invalid to date, expected YYYY-MM-DD")
+		}
+		query = query.Where("occurred_at < ?", to.AddDate(0, 0, 1))
+	}
+	if filter.NoteContains != "" {
+		query = query.Where("note ILIKE ?", "%"+escapeLike(filter.NoteContains)+"%")
+	}
+	return query, nil
+}
+
+// escapeLike escapes the LIKE wildcards in a user-supplied substring
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// checkBulkCategory rejects a category that does not exist, before the insert fails on the foreign key
+// and aborts the whole database transaction
+func checkBulkCategory(tx *gorm.DB, c *gin.Context, categoryID *uint) error {
+	if categoryID == nil {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&Category{}).Where("id = ?", *categoryID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return &bulkItemError{http.StatusBadRequest, T(c, "Category not found")}
+	}
+	return nil
+}
+
+// bulkPayeeError reports an unknown payee as a failure of the item rather than of the server
+func bulkPayeeError(c *gin.Context, err error) error {
+	if errors.Is(err, errPayeeNotFound) {
+		return &bulkItemError{http.StatusBadRequest, T(c, "Payee not found")}
+	}
+	return err
+}
+
+// softDeleteInBulk soft deletes a transaction inside a bulk request
+func softDeleteInBulk(tx *gorm.DB, c *gin.Context, transaction *Transaction, loc *time.Location) error {
+	before := *transaction
+	now := time.Now()
+	if err := updateIfVersion(tx, transaction, before.Version, map[string]interface{}{"deleted_at": now, "version": before.Version + 1}); err != nil {
+		return err
+	}
+	transaction.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	transaction.Version++
+	if err := refreshPercentBudgets(tx, c, transaction, loc); err != nil {
+		return err
+	}
+	return recordAudit(tx, c, AuditActionDelete, AuditEntityTransaction, transaction.ID, before, transaction)
+}
+
+// addTagInBulk tags a transaction; tagging counts as a change, so it bumps the version
+func addTagInBulk(tx *gorm.DB, c *gin.Context, transaction *Transaction, tag Tag) error {
+	for _, existing := range transaction.Tags {
+		if existing.ID == tag.ID {
+			return nil
+		}
+	}
+
+	before := *transaction
+	if err := updateIfVersion(tx, transaction, before.Version, map[string]interface{}{"version": before.Version + 1}); err != nil {
+		return err
+	}
+	if err := tx.Model(transaction).Omit("Tags.*").Association("Tags").Append(&tag); err != nil {
+		return err
+	}
+	transaction.Version++
+	return recordAudit(tx, c, AuditActionUpdate, AuditEntityTransaction, transaction.ID, before, transaction)
+}
+
+// findOrCreateTag returns the user's tag with the given name, ignoring case, creating it if needed
+func findOrCreateTag(tx *gorm.DB, c *gin.Context, userID uint, name string) (Tag, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxTagLength {
+		return Tag{}, &bulkItemError{http.StatusBadRequest, T(c, "Invalid tag name")}
+	}
+
+	// Tags match case-insensitively, and the spelling first used for a tag is kept
+	tag := Tag{UserID: userID, Name: name}
+	err := tx.Where("user_id = ? AND LOWER(name) = LOWER(?)", userID, name).Order("id").FirstOrCreate(&tag).Error
+	return tag, err
+}
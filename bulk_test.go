@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestFindOrCreateTagIgnoresCase(t *testing.T) {
+	setupTestDB(t, &Tag{})
+
+	first, err := findOrCreateTag(DB, nil, 1, "Groceries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"groceries", " GROCERIES "} {
+		tag, err := findOrCreateTag(DB, nil, 1, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag.ID != first.ID || tag.Name != "Groceries" {
+			t.Errorf("%q matched tag %d %q, want %d %q", name, tag.ID, tag.Name, first.ID, "Groceries")
+		}
+	}
+
+	// Tags belong to one user
+	other, err := findOrCreateTag(DB, nil, 2, "groceries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.ID == first.ID || other.Name != "groceries" {
+		t.Errorf("another user's tag reused: %+v", other)
+	}
+}
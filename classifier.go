@@ -0,0 +1,301 @@
+package main
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxSuggestions is how many category guesses GET /transactions/suggest-category returns
+const maxSuggestions = 3
+
+// maxClassifierTokenLength matches the size of ClassifierTokenCount.Token
+const maxClassifierTokenLength = 64
+
+// classifierTokens turns a transaction's note and amount into the features the classifier counts.
+// Words are lowercased and numbers dropped; the amount becomes a single order-of-magnitude token,
+// so "coffee" at 25,000 and "coffee" at 2,500,000 are told apart.
+func classifierTokens(note string, amount float64) []string {
+	var tokens []string
+	seen := make(map[string]bool)
+	words := strings.FieldsFunc(strings.ToLower(note), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if len(word) < 2 || len(word) > maxClassifierTokenLength || strings.IndexFunc(word, unicode.IsLetter) < 0 {
+			continue
+		}
+		if !seen[word] {
+			seen[word] = true
+			tokens = append(tokens, word)
+		}
+	}
+	if amount > 0 {
+		tokens = append(tokens, "#amount:"+strconv.Itoa(int(math.Log10(amount))))
+	}
+	return tokens
+}
+
+// trainClassifier adds a categorized transaction to the user's classifier counts.
+// Call it in the same database transaction that creates the transaction.
+func trainClassifier(tx *gorm.DB, t *Transaction) error {
+	if t.CategoryID == nil {
+		return nil
+	}
+	tokens := classifierTokens(t.Note, t.Amount)
+
+	err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "category_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"documents": gorm.Expr("classifier_category_count.documents + 1"),
+			"tokens":    gorm.Expr("classifier_category_count.tokens + ?", len(tokens)),
+		}),
+	}).Create(&ClassifierCategoryCount{UserID: t.UserID, CategoryID: *t.CategoryID, Documents: 1, Tokens: len(tokens)}).Error
+	if err != nil || len(tokens) == 0 {
+		return err
+	}
+
+	counts := make([]ClassifierTokenCount, len(tokens))
+	for i, token := range tokens {
+		counts[i] = ClassifierTokenCount{UserID: t.UserID, CategoryID: *t.CategoryID, Token: token, Count: 1}
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "category_id"}, {Name: "token"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"count": gorm.Expr("classifier_token_count.count + 1")}),
+	}).Create(&counts).Error
+}
+
+// untrainClassifier takes a transaction's earlier contribution back out of the classifier counts.
+// Counts never drop below zero, since transactions older than the classifier were never added.
+func untrainClassifier(tx *gorm.DB, t *Transaction) error {
+	if t.CategoryID == nil {
+		return nil
+	}
+	tokens := classifierTokens(t.Note, t.Amount)
+
+	err := tx.Model(&ClassifierCategoryCount{}).
+		Where("user_id = ? AND category_id = ? AND documents > 0", t.UserID, *t.CategoryID).
+		Updates(map[string]interface{}{
+			"documents": gorm.Expr("documents - 1"),
+			"tokens":    gorm.Expr("CASE WHEN tokens > ? THEN tokens - ? ELSE 0 END", len(tokens), len(tokens)),
+		}).Error
+	if err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ? AND category_id = ? AND documents <= 0", t.UserID, *t.CategoryID).Delete(&ClassifierCategoryCount{}).Error; err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	if err := tx.Model(&ClassifierTokenCount{}).
+		Where("user_id = ? AND category_id = ? AND token IN ? AND count > 0", t.UserID, *t.CategoryID, tokens).
+		Update("count", gorm.Expr("count - 1")).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ? AND category_id = ? AND count <= 0", t.UserID, *t.CategoryID).Delete(&ClassifierTokenCount{}).Error
+}
+
+// retrainClassifier moves an edited transaction's counts from what it was to what it is now.
+// Call it in the same database transaction that saves the edit.
+func retrainClassifier(tx *gorm.DB, before, after *Transaction) error {
+	sameCategory := (before.CategoryID == nil && after.CategoryID == nil) ||
+		(before.CategoryID != nil && after.CategoryID != nil && *before.CategoryID == *after.CategoryID)
+	if sameCategory && before.Note == after.Note && before.Amount == after.Amount {
+		return nil
+	}
+	if err := untrainClassifier(tx, before); err != nil {
+		return err
+	}
+	return trainClassifier(tx, after)
+}
+
+// rebuildClassifier recomputes a user's classifier counts from their active, categorized
+// transactions, which picks up edits, recategorizations and deletions since the last rebuild
+func rebuildClassifier(tx *gorm.DB, userID uint) error {
+	var transactions []Transaction
+	if err := tx.Select("note", "amount", "category_id").
+		Where("user_id = ? AND category_id IS NOT NULL", userID).
+		Find(&transactions).Error; err != nil {
+		return err
+	}
+
+	categories := make(map[uint]*ClassifierCategoryCount)
+	tokenCounts := make(map[uint]map[string]int)
+	for _, t := range transactions {
+		categoryID := *t.CategoryID
+		if categories[categoryID] == nil {
+			categories[categoryID] = &ClassifierCategoryCount{UserID: userID, CategoryID: categoryID}
+			tokenCounts[categoryID] = make(map[string]int)
+		}
+		tokens := classifierTokens(t.Note, t.Amount)
+		categories[categoryID].Documents++
+		categories[categoryID].Tokens += len(tokens)
+		for _, token := range tokens {
+			tokenCounts[categoryID][token]++
+		}
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(&ClassifierTokenCount{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&ClassifierCategoryCount{}).Error; err != nil {
+		return err
+	}
+
+	var categoryRows []ClassifierCategoryCount
+	var tokenRows []ClassifierTokenCount
+	for categoryID, category := range categories {
+		categoryRows = append(categoryRows, *category)
+		for token, count := range tokenCounts[categoryID] {
+			tokenRows = append(tokenRows, ClassifierTokenCount{UserID: userID, CategoryID: categoryID, Token: token, Count: count})
+		}
+	}
+	if len(categoryRows) > 0 {
+		if err := tx.CreateInBatches(categoryRows, 500).Error; err != nil {
+			return err
+		}
+	}
+	if len(tokenRows) > 0 {
+		return tx.CreateInBatches(tokenRows, 500).Error
+	}
+	return nil
+}
+
+// rebuildClassifiers refreshes the classifier of every user with transactions
+func rebuildClassifiers() {
+	var userIDs []uint
+	if err := DB.Model(&Transaction{}).Distinct("user_id").Pluck("user_id", &userIDs).Error; err != nil {
+		log.Println("Failed to list users for classifier rebuild:", err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		if err := DB.Transaction(func(tx *gorm.DB) error { return rebuildClassifier(tx, userID) }); err != nil {
+			log.Printf("Failed to rebuild classifier for user %d: %v", userID, err)
+		}
+	}
+}
+
+// categorySuggestion is one ranked category guess
+type categorySuggestion struct {
+	Category   Category `json:"category"`
+	Confidence float64  `json:"confidence"` // Posterior probability among the user's categories, 0 to 1
+}
+
+// suggestCategories ranks the user's categories for a note and amount with multinomial naive Bayes
+// and Laplace smoothing, using only the counts learned from the user's own transactions
+func suggestCategories(userID uint, note string, amount float64) ([]categorySuggestion, error) {
+	var categories []ClassifierCategoryCount
+	if err := DB.Where("user_id = ? AND documents > 0", userID).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	if len(categories) == 0 {
+		return []categorySuggestion{}, nil
+	}
+
+	tokens := classifierTokens(note, amount)
+	var tokenCounts []ClassifierTokenCount
+	if len(tokens) > 0 {
+		if err := DB.Where("user_id = ? AND token IN ?", userID, tokens).Find(&tokenCounts).Error; err != nil {
+			return nil, err
+		}
+	}
+	var vocabulary int64
+	if err := DB.Model(&ClassifierTokenCount{}).Where("user_id = ?", userID).Distinct("token").Count(&vocabulary).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]map[string]int)
+	for _, tc := range tokenCounts {
+		if counts[tc.CategoryID] == nil {
+			counts[tc.CategoryID] = make(map[string]int)
+		}
+		counts[tc.CategoryID][tc.Token] = tc.Count
+	}
+
+	totalDocuments := 0
+	for _, category := range categories {
+		totalDocuments += category.Documents
+	}
+
+	// Work in log space, then normalize with the log-sum-exp trick to get probabilities.
+	// The extra 1 in the denominator keeps it positive before anything has been learned.
+	scores := make([]float64, len(categories))
+	best := math.Inf(-1)
+	for i, category := range categories {
+		score := math.Log(float64(category.Documents) / float64(totalDocuments))
+		for _, token := range tokens {
+			score += math.Log(float64(counts[category.CategoryID][token]+1) / float64(category.Tokens+int(vocabulary)+1))
+		}
+		scores[i] = score
+		best = math.Max(best, score)
+	}
+	sum := 0.0
+	for _, score := range scores {
+		sum += math.Exp(score - best)
+	}
+
+	suggestions := make([]categorySuggestion, len(categories))
+	categoryIDs := make([]uint, len(categories))
+	for i, category := range categories {
+		suggestions[i] = categorySuggestion{Category: Category{ID: category.CategoryID}, Confidence: math.Exp(scores[i]-best) / sum}
+		categoryIDs[i] = category.CategoryID
+	}
+	sort.Slice(suggestions, func(i, j int) bool { return suggestions[i].Confidence > suggestions[j].Confidence })
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+
+	var names []Category
+	DB.Where("id IN ?", categoryIDs).Find(&names)
+	for i := range suggestions {
+		for _, category := range names {
+			if category.ID == suggestions[i].Category.ID {
+				suggestions[i].Category = category
+			}
+		}
+	}
+	return suggestions, nil
+}
+
+// SuggestCategory returns ranked category guesses for a note and optional amount
+func SuggestCategory(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	note := c.Query("note")
+	if strings.TrimSpace(note) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Note is required")})
+		return
+	}
+	var amount float64
+	if value := c.Query("amount"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Invalid amount")})
+			return
+		}
+		amount = parsed
+	}
+
+	suggestions, err := suggestCategories(userID.(uint), note, amount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to suggest a category")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+}
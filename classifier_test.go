@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRetrainClassifierMovesCountsToNewCategory(t *testing.T) {
+	setupTestDB(t, &ClassifierCategoryCount{}, &ClassifierTokenCount{})
+
+	food, transport := uint(1), uint(2)
+	first := Transaction{UserID: 1, CategoryID: &food, Note: "Coffee shop", Amount: 25000}
+	other := Transaction{UserID: 1, CategoryID: &food, Note: "Coffee beans", Amount: 90000}
+	for _, transaction := range []*Transaction{&first, &other} {
+		if err := trainClassifier(DB, transaction); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	edited := first
+	edited.CategoryID = &transport
+	if err := retrainClassifier(DB, &first, &edited); err != nil {
+		t.Fatal(err)
+	}
+
+	var categories []ClassifierCategoryCount
+	DB.Order("category_id").Find(&categories)
+	want := []ClassifierCategoryCount{
+		{UserID: 1, CategoryID: food, Documents: 1, Tokens: 3},
+		{UserID: 1, CategoryID: transport, Documents: 1, Tokens: 3},
+	}
+	if len(categories) != len(want) || categories[0] != want[0] || categories[1] != want[1] {
+		t.Fatalf("category counts = %+v, want %+v", categories, want)
+	}
+
+	tokenCount := func(categoryID uint, token string) int {
+		var count ClassifierTokenCount
+		DB.Where("category_id = ? AND token = ?", categoryID, token).Find(&count)
+		return count.Count
+	}
+	if got := tokenCount(food, "coffee"); got != 1 {
+		t.Errorf("food coffee = %d, want 1", got)
+	}
+	if got := tokenCount(food, "shop"); got != 0 {
+		t.Errorf("food shop = %d, want 0", got)
+	}
+	if got := tokenCount(transport, "shop"); got != 1 {
+		t.Errorf("transport shop = %d, want 1", got)
+	}
+
+	// Clearing the category only removes the transaction's counts
+	cleared := edited
+	cleared.CategoryID = nil
+	if err := retrainClassifier(DB, &edited, &cleared); err != nil {
+		t.Fatal(err)
+	}
+	var remaining int64
+	DB.Model(&ClassifierCategoryCount{}).Where("category_id = ?", transport).Count(&remaining)
+	if remaining != 0 {
+		t.Errorf("transport still has %d count rows after its only transaction left", remaining)
+	}
+}
+
+// classifierRouter serves the given handler as user 1
+func classifierRouter(method, path string, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Handle(method, path, func(c *gin.Context) { c.Set("userID", uint(1)) }, handler)
+	return router
+}
+
+// classifierDocuments returns how many transactions the model has counted under a category
+func classifierDocuments(categoryID uint) int {
+	var count ClassifierCategoryCount
+	DB.Where("user_id = ? AND category_id = ?", 1, categoryID).Find(&count)
+	return count.Documents
+}
+
+func TestApplyRulesTrainsTheClassifier(t *testing.T) {
+	setupTestDB(t, &Transaction{}, &Tag{}, &Rule{}, &AuditLog{}, &UserSettings{}, &ClassifierCategoryCount{}, &ClassifierTokenCount{})
+
+	transport := uint(2)
+	rule := Rule{UserID: 1, Name: "Rides", Enabled: true, CategoryID: &transport, Tags: []string{},
+		Conditions: []RuleCondition{{Field: RuleFieldNote, Operator: RuleOpContains, Value: "grab"}}}
+	transaction := Transaction{UserID: 1, Type: "Expense", Amount: 30000, Currency: "IDR", ExchangeRate: 1, Note: "Grab ride", OccurredAt: time.Now(), Version: 1}
+	for _, record := range []interface{}{&rule, &transaction} {
+		if err := DB.Create(record).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router := classifierRouter(http.MethodPost, "/rules/apply", ApplyRules)
+	body := fmt.Sprintf(`{"filter": {"ids": [%d]}}`, transaction.ID)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/rules/apply", strings.NewReader(body)))
+	if response.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", response.Code, response.Body.String())
+	}
+	if got := classifierDocuments(transport); got != 1 {
+		t.Errorf("transport documents = %d, want 1 after the rule filed the transaction", got)
+	}
+}
+
+func TestRevertTransactionRetrainsTheClassifier(t *testing.T) {
+	setupTestDB(t, &Transaction{}, &AuditLog{}, &UserSettings{}, &ClassifierCategoryCount{}, &ClassifierTokenCount{})
+
+	food, transport := uint(1), uint(2)
+	transaction := Transaction{UserID: 1, CategoryID: &food, Type: "Expense", Amount: 30000, Currency: "IDR", ExchangeRate: 1, Note: "Grab ride", OccurredAt: time.Now(), Version: 1}
+	if err := DB.Create(&transaction).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := recordAudit(DB, nil, AuditActionCreate, AuditEntityTransaction, transaction.ID, nil, transaction); err != nil {
+		t.Fatal(err)
+	}
+	if err := trainClassifier(DB, &transaction); err != nil {
+		t.Fatal(err)
+	}
+
+	// Version 2 moved it to transport; reverting to version 1 moves it back
+	edited := transaction
+	edited.CategoryID = &transport
+	edited.Version = 2
+	if err := DB.Save(&edited).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := retrainClassifier(DB, &transaction, &edited); err != nil {
+		t.Fatal(err)
+	}
+
+	router := classifierRouter(http.MethodPost, "/transactions/:id/revert", RevertTransaction)
+	request := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/transactions/%d/revert?version=1", transaction.ID), nil)
+	request.Header.Set("If-Match", entityETag(edited.Version))
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", response.Code, response.Body.String())
+	}
+	if food, transport := classifierDocuments(food), classifierDocuments(transport); food != 1 || transport != 0 {
+		t.Errorf("documents food %d, transport %d; want the transaction counted under food again", food, transport)
+	}
+}
@@ -34,10 +34,18 @@ func InitDatabase() {
 	}
 
 	// Drop existing tables (for development/testing purposes)
-	DB.Migrator().DropTable(&User{}, &Category{}, &Transaction{}, &Budget{})
+	DB.Migrator().DropTable(&User{}, &Category{}, &Tag{}, "transaction_tag", &Payee{}, &PayeeAlias{}, &TransactionAnomaly{}, &Transaction{}, &Budget{}, &EnvelopeAssignment{}, &BudgetTemplate{}, &UserSettings{}, &UserToken{}, &RecoveryCode{}, &UserIdentity{}, &PersonalAccessToken{}, &ExchangeRate{}, &AuditLog{}, &IdempotencyKey{}, &Rule{}, &ClassifierCategoryCount{}, &ClassifierTokenCount{})
 
 	// AutoMigrate creates or updates tables based on the struct definitions
-	DB.AutoMigrate(&User{}, &Category{}, &Transaction{}, &Budget{})
+	DB.AutoMigrate(&User{}, &Category{}, &Tag{}, &Payee{}, &PayeeAlias{}, &Transaction{}, &Budget{}, &EnvelopeAssignment{}, &BudgetTemplate{}, &UserSettings{}, &UserToken{}, &RecoveryCode{}, &UserIdentity{}, &PersonalAccessToken{}, &ExchangeRate{}, &AuditLog{}, &IdempotencyKey{}, &Rule{}, &ClassifierCategoryCount{}, &ClassifierTokenCount{}, &TransactionAnomaly{})
+
+	// Backfill the transaction date for rows created before OccurredAt existed
+	DB.Exec("UPDATE transaction SET occurred_at = created_at WHERE occurred_at IS NULL")
+
+	// Backfill the period of budgets created when every budget covered one month
+	DB.Exec("UPDATE budget SET start_date = month || '-01', " +
+		"end_date = TO_CHAR(TO_DATE(month || '-01', 'YYYY-MM-DD') + INTERVAL '1 month' - INTERVAL '1 day', 'YYYY-MM-DD') " +
+		"WHERE start_date = ''")
 
 	fmt.Println("Database connected & migrated successfully!") // Print success message
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// setupTestDB points DB at a fresh in-memory SQLite database with the given tables
+func setupTestDB(t *testing.T, models ...interface{}) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{SingularTable: true},
+		Logger:         logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	// Every connection to ":memory:" opens a new, empty database, so keep to one
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+
+	previous := DB
+	DB = db
+	t.Cleanup(func() {
+		DB = previous
+		sqlDB.Close()
+	})
+}
+
+// useTestKeys signs and verifies tokens with a throwaway key for the duration of the test
+func useTestKeys(t *testing.T) {
+	keys, err := newEphemeralKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := AppKeys
+	AppKeys = keys
+	t.Cleanup(func() { AppKeys = previous })
+}
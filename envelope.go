@@ -0,0 +1,341 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AuditEntityEnvelope identifies envelope ledger entries in the audit log
+const AuditEntityEnvelope = "envelope_assignment"
+
+// maxEnvelopeNoteLength matches the size of EnvelopeAssignment.Note
+const maxEnvelopeNoteLength = 255
+
+// Envelope is one category's envelope in a month, with amounts in IDR
+type Envelope struct {
+	Category  Category `json:"category"`
+	Assigned  float64  `json:"assigned"`  // Assigned to the envelope this month
+	Spent     float64  `json:"spent"`     // Expenses in the category this month
+	Available float64  `json:"available"` // Everything assigned minus everything spent so far, carried over month to month
+}
+
+// envelopeMonthReport is the state of a user's envelopes at the end of a month
+type envelopeMonthReport struct {
+	Month        string     `json:"month"`
+	ToBeAssigned float64    `json:"to_be_assigned"` // Income not yet assigned to an envelope; negative when over-assigned
+	Envelopes    []Envelope `json:"envelopes"`
+}
+
+// envelopeMonth reads the ?month= parameter (YYYY-MM), defaulting to the current month in loc
+func envelopeMonth(c *gin.Context, loc *time.Location) (string, time.Time, time.Time, error) {
+	month := c.Query("month")
+	if month == "" {
+		month = time.Now().In(loc).Format("2006-01")
+	}
+	start, end, err := monthRange(month, loc)
+	return month, start, end, err
+}
+
+// requireEnvelopeMode rejects the request unless the user opted in to envelope budgeting
+func requireEnvelopeMode(c *gin.Context, userID uint) bool {
+	if !loadUserSettings(userID).EnvelopeBudgeting {
+		c.JSON(http.StatusConflict, gin.H{"error": T(c, "Envelope budgeting is not enabled")})
+		return false
+	}
+	return true
+}
+
+// buildEnvelopeReport computes the envelopes of a month. Envelopes start in the month of the
+// user's first assignment: income from any time fills the pool, spending before that month is
+// treated as already paid out of it, and uncategorized spending afterwards comes out of the pool too.
+func buildEnvelopeReport(userID uint, month string, monthStart, monthEnd time.Time, loc *time.Location) (envelopeMonthReport, error) {
+	report := envelopeMonthReport{Month: month, Envelopes: []Envelope{}}
+
+	var firstMonth sql.NullString
+	if err := DB.Model(&EnvelopeAssignment{}).Where("user_id = ?", userID).Select("MIN(month)").Scan(&firstMonth).Error; err != nil {
+		return report, err
+	}
+	envelopeStart := monthStart
+	if firstMonth.Valid && firstMonth.String < month {
+		start, _, err := monthRange(firstMonth.String, loc)
+		if err != nil {
+			return report, err
+		}
+		envelopeStart = start
+	}
+
+	var pool struct {
+		Income        float64
+		SpentBefore   float64
+		Uncategorized float64
+	}
+	err := DB.Model(&Transaction{}).
+		Select("COALESCE(SUM(CASE WHEN type = 'Income' THEN amount * exchange_rate ELSE 0 END), 0) AS income, "+
+			"COALESCE(SUM(CASE WHEN type = 'Expense' AND occurred_at < ? THEN amount * exchange_rate ELSE 0 END), 0) AS spent_before, "+
+			"COALESCE(SUM(CASE WHEN type = 'Expense' AND occurred_at >= ? AND category_id IS NULL THEN amount * exchange_rate ELSE 0 END), 0) AS uncategorized",
+			envelopeStart, envelopeStart).
+		Where("user_id = ? AND deleted_at IS NULL AND occurred_at < ?", userID, monthEnd).
+		Scan(&pool).Error
+	if err != nil {
+		return report, err
+	}
+
+	var assigned []struct {
+		CategoryID uint
+		Total      float64
+		ThisMonth  float64
+	}
+	err = DB.Model(&EnvelopeAssignment{}).
+		Select("category_id, SUM(amount) AS total, SUM(CASE WHEN month = ? THEN amount ELSE 0 END) AS this_month", month).
+		Where("user_id = ? AND month <= ?", userID, month).
+		Group("category_id").
+		Scan(&assigned).Error
+	if err != nil {
+		return report, err
+	}
+
+	var spent []struct {
+		CategoryID uint
+		Total      float64
+		ThisMonth  float64
+	}
+	err = DB.Model(&Transaction{}).
+		Select("category_id, SUM(amount * exchange_rate) AS total, "+
+			"SUM(CASE WHEN occurred_at >= ? THEN amount * exchange_rate ELSE 0 END) AS this_month", monthStart).
+		Where("user_id = ? AND type = ? AND category_id IS NOT NULL AND deleted_at IS NULL", userID, "Expense").
+		Where("occurred_at >= ? AND occurred_at < ?", envelopeStart, monthEnd).
+		Group("category_id").
+		Scan(&spent).Error
+	if err != nil {
+		return report, err
+	}
+
+	envelopes := make(map[uint]*Envelope)
+	envelope := func(categoryID uint) *Envelope {
+		if envelopes[categoryID] == nil {
+			envelopes[categoryID] = &Envelope{Category: Category{ID: categoryID}}
+		}
+		return envelopes[categoryID]
+	}
+	totalAssigned := 0.0
+	for _, row := range assigned {
+		e := envelope(row.CategoryID)
+		e.Assigned = row.ThisMonth
+		e.Available += row.Total
+		totalAssigned += row.Total
+	}
+	for _, row := range spent {
+		e := envelope(row.CategoryID)
+		e.Spent = row.ThisMonth
+		e.Available -= row.Total
+	}
+
+	report.ToBeAssigned = pool.Income - pool.SpentBefore - pool.Uncategorized - totalAssigned
+	if len(envelopes) == 0 {
+		return report, nil
+	}
+
+	categoryIDs := make([]uint, 0, len(envelopes))
+	for categoryID := range envelopes {
+		categoryIDs = append(categoryIDs, categoryID)
+	}
+	var categories []Category
+	if err := DB.Where("id IN ?", categoryIDs).Find(&categories).Error; err != nil {
+		return report, err
+	}
+	for _, category := range categories {
+		envelopes[category.ID].Category = category
+	}
+	for _, e := range envelopes {
+		report.Envelopes = append(report.Envelopes, *e)
+	}
+	sort.Slice(report.Envelopes, func(i, j int) bool { return report.Envelopes[i].Category.Name < report.Envelopes[j].Category.Name })
+	return report, nil
+}
+
+// GetEnvelopes shows the pool of money to be assigned and every envelope's balance for a month
+func GetEnvelopes(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+	if !requireEnvelopeMode(c, userID.(uint)) {
+		return
+	}
+
+	loc := userLocation(userID.(uint))
+	month, start, end, err := envelopeMonth(c, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := buildEnvelopeReport(userID.(uint), month, start, end, loc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch envelopes")})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetEnvelopeLedger lists the assignments and moves recorded for a month
+func GetEnvelopeLedger(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+	if !requireEnvelopeMode(c, userID.(uint)) {
+		return
+	}
+
+	month, _, _, err := envelopeMonth(c, userLocation(userID.(uint)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var entries []EnvelopeAssignment
+	if err := DB.Preload("Category").
+		Where("user_id = ? AND month = ?", userID, month).
+		Order("created_at ASC, id ASC").
+		Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch envelope ledger")})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// AssignToEnvelope moves money between the pool and a category envelope. A positive amount
+// assigns money from the pool; a negative amount returns it.
+func AssignToEnvelope(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+	if !requireEnvelopeMode(c, userID.(uint)) {
+		return
+	}
+
+	var input struct {
+		CategoryID uint    `json:"category_id" binding:"required"`
+		Amount     float64 `json:"amount" binding:"required"` // In IDR
+		Month      string  `json:"month"`                     // Defaults to the current month
+		Note       string  `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry := EnvelopeAssignment{UserID: userID.(uint), CategoryID: input.CategoryID, Amount: input.Amount, Month: input.Month, Note: input.Note}
+	if !validateEnvelopeEntry(c, &entry, userLocation(userID.(uint))) {
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionCreate, AuditEntityEnvelope, entry.ID, nil, entry)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to assign money")})
+		return
+	}
+
+	DB.Preload("Category").First(&entry, entry.ID)
+	c.JSON(http.StatusCreated, entry)
+}
+
+// MoveBetweenEnvelopes moves money from one category envelope to another. Both sides are recorded
+// in the ledger, each pointing at the other envelope.
+func MoveBetweenEnvelopes(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+	if !requireEnvelopeMode(c, userID.(uint)) {
+		return
+	}
+
+	var input struct {
+		FromCategoryID uint    `json:"from_category_id" binding:"required"`
+		ToCategoryID   uint    `json:"to_category_id" binding:"required"`
+		Amount         float64 `json:"amount" binding:"required"` // In IDR
+		Month          string  `json:"month"`                     // Defaults to the current month
+		Note           string  `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Amount must be positive")})
+		return
+	}
+	if input.FromCategoryID == input.ToCategoryID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Cannot move money to the same envelope")})
+		return
+	}
+
+	loc := userLocation(userID.(uint))
+	from := EnvelopeAssignment{UserID: userID.(uint), CategoryID: input.FromCategoryID, CounterpartCategoryID: &input.ToCategoryID, Amount: -input.Amount, Month: input.Month, Note: input.Note}
+	to := EnvelopeAssignment{UserID: userID.(uint), CategoryID: input.ToCategoryID, CounterpartCategoryID: &input.FromCategoryID, Amount: input.Amount, Month: input.Month, Note: input.Note}
+	if !validateEnvelopeEntry(c, &from, loc) || !validateEnvelopeEntry(c, &to, loc) {
+		return
+	}
+
+	entries := []EnvelopeAssignment{from, to}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&entries).Error; err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := recordAudit(tx, c, AuditActionCreate, AuditEntityEnvelope, entry.ID, nil, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to move money")})
+		return
+	}
+
+	DB.Preload("Category").Where("id IN ?", []uint{entries[0].ID, entries[1].ID}).Order("id ASC").Find(&entries)
+	c.JSON(http.StatusCreated, entries)
+}
+
+// validateEnvelopeEntry fills in the default month and checks a ledger entry, writing a 400 response
+// when it is invalid
+func validateEnvelopeEntry(c *gin.Context, entry *EnvelopeAssignment, loc *time.Location) bool {
+	if entry.Month == "" {
+		entry.Month = time.Now().In(loc).Format("2006-01")
+	}
+	if _, _, err := monthRange(entry.Month, loc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if len(entry.Note) > maxEnvelopeNoteLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Note is too long")})
+		return false
+	}
+
+	var count int64
+	if err := DB.Model(&Category{}).Where("id = ?", entry.CategoryID).Count(&count).Error; err != nil || count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Category not found")})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errVersionConflict is returned when a row changed between reading it and writing it back
+var errVersionConflict = errors.New("version conflict")
+
+// entityETag formats the ETag of a versioned entity; the version changes on every write
+func entityETag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// setETag sets the ETag response header for a versioned entity
+func setETag(c *gin.Context, version uint) {
+	c.Header("ETag", entityETag(version))
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header lists the ETag.
+// Weak validators compare equal to their strong form, and "*" matches any ETag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified answers a conditional GET with 304 when the client already has the current version.
+// It sets the ETag header either way and returns true when the response has been written.
+func notModified(c *gin.Context, version uint) bool {
+	setETag(c, version)
+	if header := c.GetHeader("If-None-Match"); header != "" && etagMatches(header, entityETag(version)) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// checkIfMatch makes sure the client is writing on top of the current version. A missing
+// If-Match header is rejected with 428 when required and ignored otherwise; a stale one gets 412.
+// It aborts the request and returns false when the write must not go ahead.
+func checkIfMatch(c *gin.Context, version uint, required bool) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		if !required {
+			return true
+		}
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": T(c, "If-Match header required")})
+		c.Abort()
+		return false
+	}
+
+	if !etagMatches(header, entityETag(version)) {
+		setETag(c, version)
+		preconditionFailed(c)
+		return false
+	}
+	return true
+}
+
+// preconditionFailed rejects a write that lost the race to another request
+func preconditionFailed(c *gin.Context) {
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": T(c, "Resource was modified by another request")})
+	c.Abort()
+}
+
+// saveIfVersion writes every field of model, but only if its row is still at the expected version.
+// Checking the version in the UPDATE itself closes the gap between the If-Match check and the write.
+func saveIfVersion(tx *gorm.DB, model interface{}, expected uint) error {
+	result := tx.Unscoped().Model(model).Omit(clause.Associations).Select("*").Where("version = ?", expected).Updates(model)
+	return versionResult(result)
+}
+
+// updateIfVersion writes the given columns of model's row if it is still at the expected version
+func updateIfVersion(tx *gorm.DB, model interface{}, expected uint, values map[string]interface{}) error {
+	result := tx.Unscoped().Model(model).Where("version = ?", expected).Updates(values)
+	return versionResult(result)
+}
+
+// versionResult turns an UPDATE that matched no row into errVersionConflict
+func versionResult(result *gorm.DB) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errVersionConflict
+	}
+	return nil
+}
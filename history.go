@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AuditActionRevert is recorded when an entity is restored to an earlier version
+const AuditActionRevert = "revert"
+
+// EntityVersion is one entry in an entity's version history
+type EntityVersion struct {
+	Version   uint            `json:"version"`
+	Action    string          `json:"action"`
+	ActorID   uint            `json:"actor_id"`
+	ChangedAt time.Time       `json:"changed_at"`
+	Changes   json.RawMessage `json:"changes"`
+	Snapshot  json.RawMessage `json:"snapshot"` // The entity's state at this version
+}
+
+// entityHistory returns the recorded versions of an entity, oldest first
+func entityHistory(entityType string, entityID uint) ([]EntityVersion, error) {
+	var entries []AuditLog
+	err := DB.Where("entity_type = ? AND entity_id = ? AND version > 0", entityType, strconv.FormatUint(uint64(entityID), 10)).
+		Order("version ASC").
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]EntityVersion, 0, len(entries))
+	for _, entry := range entries {
+		versions = append(versions, EntityVersion{
+			Version:   entry.Version,
+			Action:    entry.Action,
+			ActorID:   entry.ActorID,
+			ChangedAt: entry.CreatedAt,
+			Changes:   entry.Changes,
+			Snapshot:  entry.After,
+		})
+	}
+	return versions, nil
+}
+
+// loadEntityVersion decodes the snapshot of an entity at the given version into dest
+func loadEntityVersion(entityType string, entityID uint, version string, dest interface{}) error {
+	var entry AuditLog
+	err := DB.Where("entity_type = ? AND entity_id = ? AND version = ?", entityType, strconv.FormatUint(uint64(entityID), 10), version).
+		First(&entry).Error
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(entry.After, dest)
+}
+
+// GetTransactionHistory lists the prior versions of a transaction
+func GetTransactionHistory(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	// Deleted transactions keep their history
+	var transaction Transaction
+	if err := DB.Unscoped().Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&transaction).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Transaction not found")})
+		return
+	}
+
+	versions, err := entityHistory(AuditEntityTransaction, transaction.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch history")})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+// RevertTransaction restores a transaction's fields to those of an earlier version.
+// The revert is itself a new version, so it can be undone the same way.
+func RevertTransaction(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var transaction Transaction
+	if err := DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&transaction).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Transaction not found")})
+		return
+	}
+
+	if !checkIfMatch(c, transaction.Version, true) {
+		return
+	}
+
+	var reverted Transaction
+	if err := loadEntityVersion(AuditEntityTransaction, transaction.ID, c.Query("version"), &reverted); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Version not found")})
+		return
+	}
+
+	// Only the data fields come from the old version; identity, ownership and bookkeeping stay current
+	reverted.ID = transaction.ID
+	reverted.UserID = transaction.UserID
+	reverted.CreatedAt = transaction.CreatedAt
+	reverted.DeletedAt = transaction.DeletedAt
+	reverted.Version = transaction.Version + 1
+
+	loc := userLocation(userID.(uint))
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveIfVersion(tx, &reverted, transaction.Version); err != nil {
+			return err
+		}
+		if err := retrainClassifier(tx, &transaction, &reverted); err != nil {
+			return err
+		}
+		if err := refreshPercentBudgetsForEdit(tx, c, &transaction, &reverted, loc); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionRevert, AuditEntityTransaction, reverted.ID, transaction, reverted)
+	})
+	if errors.Is(err, errVersionConflict) {
+		preconditionFailed(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update transaction")})
+		return
+	}
+
+	flagAnomalies([]*Transaction{&reverted})
+	DB.Preload("Category").Preload("Anomalies").First(&reverted, reverted.ID)
+
+	setETag(c, reverted.Version)
+	c.JSON(http.StatusOK, reverted)
+}
+
+// GetBudgetHistory lists the prior versions of a budget
+func GetBudgetHistory(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var budget Budget
+	if err := DB.Unscoped().Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&budget).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Budget not found")})
+		return
+	}
+
+	versions, err := entityHistory(AuditEntityBudget, budget.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch history")})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+// RevertBudget restores a budget's fields to those of an earlier version
+func RevertBudget(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var budget Budget
+	if err := DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&budget).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Budget not found")})
+		return
+	}
+
+	if !checkIfMatch(c, budget.Version, true) {
+		return
+	}
+
+	var reverted Budget
+	if err := loadEntityVersion(AuditEntityBudget, budget.ID, c.Query("version"), &reverted); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Version not found")})
+		return
+	}
+
+	reverted.ID = budget.ID
+	reverted.UserID = budget.UserID
+	reverted.CreatedAt = budget.CreatedAt
+	reverted.DeletedAt = budget.DeletedAt
+	reverted.Version = budget.Version + 1
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveIfVersion(tx, &reverted, budget.Version); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionRevert, AuditEntityBudget, reverted.ID, budget, reverted)
+	})
+	if errors.Is(err, errVersionConflict) {
+		preconditionFailed(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update budget")})
+		return
+	}
+
+	DB.Preload("Category").First(&reverted, reverted.ID)
+
+	setETag(c, reverted.Version)
+	c.JSON(http.StatusOK, reverted)
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultLocale is used when neither the request nor the user's settings name a supported locale
+const defaultLocale = "en"
+
+// messageCatalog maps a locale to translations of the English API messages.
+// English is the source language, so it needs no entries of its own.
+var messageCatalog = map[string]map[string]string{
+	"en": {},
+	"id": {
+		"Authorization token missing":                              "Token otorisasi tidak ditemukan",
+		"Budget not found":                                         "Anggaran tidak ditemukan",
+		"Failed to create budget":                                  "Gagal membuat anggaran",
+		"Failed to create transaction":                             "Gagal membuat transaksi",
+		"Failed to create user":                                    "Gagal membuat pengguna",
+		"Failed to delete budget":                                  "Gagal menghapus anggaran",
+		"Failed to delete transaction":                             "Gagal menghapus transaksi",
+		"Failed to fetch budgets":                                  "Gagal mengambil data anggaran",
+		"Failed to fetch summary data":                             "Gagal mengambil data ringkasan",
+		"Failed to fetch total spent data":                         "Gagal mengambil data total pengeluaran",
+		"Failed to fetch trend data":                               "Gagal mengambil data tren",
+		"Failed to generate token":                                 "Gagal membuat token",
+		"Failed to hash password":                                  "Gagal mengenkripsi kata sandi",
+		"Failed to restore budget":                                 "Gagal memulihkan anggaran",
+		"Failed to restore transaction":                            "Gagal memulihkan transaksi",
+		"Failed to update transaction":                             "Gagal memperbarui transaksi",
+		"Failed to update settings":                                "Gagal memperbarui pengaturan",
+		"Invalid request":                                          "Permintaan tidak valid",
+		"Invalid timezone":                                         "Zona waktu tidak valid",
+		"Invalid token":                                            "Token tidak valid",
+		"Invalid user ID in token":                                 "ID pengguna pada token tidak valid",
+		"Token expiration (exp) missing":                           "Masa berlaku token (exp) tidak ditemukan",
+		"Token expired":                                            "Token sudah kedaluwarsa",
+		"Transaction not found":                                    "Transaksi tidak ditemukan",
+		"Unauthorized":                                             "Tidak diizinkan",
+		"Unsupported locale":                                       "Bahasa tidak didukung",
+		"User not found":                                           "Pengguna tidak ditemukan",
+		"Cannot demote the last admin":                             "Tidak dapat menurunkan admin terakhir",
+		"Budget deleted (soft deleted)":                            "Anggaran dihapus (dapat dipulihkan)",
+		"Budget restored":                                          "Anggaran dipulihkan",
+		"Login successful":                                         "Berhasil masuk",
+		"Logout successful":                                        "Berhasil keluar",
+		"Account restored":                                         "Akun dipulihkan",
+		"Account scheduled for deletion":                           "Akun dijadwalkan untuk dihapus",
+		"Email is already in use":                                  "Email sudah digunakan",
+		"Failed to change password":                                "Gagal mengubah kata sandi",
+		"Failed to delete account":                                 "Gagal menghapus akun",
+		"Failed to restore account":                                "Gagal memulihkan akun",
+		"Failed to update user":                                    "Gagal memperbarui pengguna",
+		"Identity provider is unavailable":                         "Penyedia identitas tidak tersedia",
+		"Identity provider login failed":                           "Gagal masuk melalui penyedia identitas",
+		"Verify your email before signing in with this provider":   "Verifikasi email Anda sebelum masuk melalui penyedia ini",
+		"Login session expired, please try again":                  "Sesi masuk kedaluwarsa, silakan coba lagi",
+		"Unknown identity provider":                                "Penyedia identitas tidak dikenal",
+		"Password changed":                                         "Kata sandi berhasil diubah",
+		"Failed to fetch tokens":                                   "Gagal mengambil daftar token",
+		"Failed to revoke token":                                   "Gagal mencabut token",
+		"This endpoint is not available to API tokens":             "Endpoint ini tidak tersedia untuk token API",
+		"Token lacks the required scope":                           "Token tidak memiliki cakupan yang diperlukan",
+		"Token not found":                                          "Token tidak ditemukan",
+		"Token revoked":                                            "Token dicabut",
+		"Unknown scope":                                            "Cakupan tidak dikenal",
+		"Account disabled":                                         "Akun dinonaktifkan",
+		"Category already exists":                                  "Kategori sudah ada",
+		"Category deleted":                                         "Kategori dihapus",
+		"Category is in use":                                       "Kategori sedang digunakan",
+		"Category not found":                                       "Kategori tidak ditemukan",
+		"Exchange rate deleted":                                    "Kurs dihapus",
+		"Exchange rate not found":                                  "Kurs tidak ditemukan",
+		"Failed to create category":                                "Gagal membuat kategori",
+		"Failed to delete category":                                "Gagal menghapus kategori",
+		"Failed to fetch exchange rates":                           "Gagal mengambil data kurs",
+		"Failed to fetch users":                                    "Gagal mengambil data pengguna",
+		"Failed to update category":                                "Gagal memperbarui kategori",
+		"Failed to update exchange rate":                           "Gagal memperbarui kurs",
+		"Failed to fetch audit log":                                "Gagal mengambil log audit",
+		"Failed to update budget":                                  "Gagal memperbarui anggaran",
+		"Failed to fetch history":                                  "Gagal mengambil riwayat",
+		"Forbidden":                                                "Akses ditolak",
+		"Invalid currency code":                                    "Kode mata uang tidak valid",
+		"User disabled":                                            "Pengguna dinonaktifkan",
+		"User restored":                                            "Pengguna dipulihkan",
+		"Version not found":                                        "Versi tidak ditemukan",
+		"If-Match header required":                                 "Header If-Match wajib disertakan",
+		"Resource was modified by another request":                 "Data telah diubah oleh permintaan lain",
+		"Provide either operations or a filter and an action":      "Sertakan daftar operasi atau filter beserta aksinya",
+		"Too many items in one bulk request":                       "Terlalu banyak item dalam satu permintaan massal",
+		"Bulk request failed, no changes were applied":             "Permintaan massal gagal, tidak ada perubahan yang disimpan",
+		"Not applied because an earlier item failed":               "Tidak diterapkan karena item sebelumnya gagal",
+		"Unknown bulk operation":                                   "Operasi massal tidak dikenal",
+		"Unknown bulk action":                                      "Aksi massal tidak dikenal",
+		"Invalid tag name":                                         "Nama tag tidak valid",
+		"Failed to delete item":                                    "Gagal menghapus item",
+		"Failed to empty trash":                                    "Gagal mengosongkan tempat sampah",
+		"Failed to fetch trash":                                    "Gagal mengambil isi tempat sampah",
+		"Item permanently deleted":                                 "Item dihapus permanen",
+		"Trash emptied":                                            "Tempat sampah dikosongkan",
+		"Unknown item type":                                        "Jenis item tidak dikenal",
+		"Failed to process idempotency key":                        "Gagal memproses idempotency key",
+		"Idempotency key is too long":                              "Idempotency key terlalu panjang",
+		"Idempotency key was already used for a different request": "Idempotency key sudah digunakan untuk permintaan lain",
+		"A request with this idempotency key is still in progress": "Permintaan dengan idempotency key ini masih diproses",
+		"Failed to apply rules":                                    "Gagal menerapkan aturan",
+		"Failed to create rule":                                    "Gagal membuat aturan",
+		"Failed to delete rule":                                    "Gagal menghapus aturan",
+		"Failed to fetch rules":                                    "Gagal mengambil daftar aturan",
+		"Failed to update rule":                                    "Gagal memperbarui aturan",
+		"Rule deleted":                                             "Aturan dihapus",
+		"Rule not found":                                           "Aturan tidak ditemukan",
+		"Failed to suggest a category":                             "Gagal menyarankan kategori",
+		"Invalid amount":                                           "Jumlah tidak valid",
+		"Note is required":                                         "Catatan wajib diisi",
+		"Failed to create payee":                                   "Gagal membuat penerima",
+		"Failed to delete payee":                                   "Gagal menghapus penerima",
+		"Failed to fetch payee report":                             "Gagal mengambil laporan penerima",
+		"Failed to fetch payees":                                   "Gagal mengambil daftar penerima",
+		"Failed to update payee":                                   "Gagal memperbarui penerima",
+		"Invalid payee alias":                                      "Alias penerima tidak valid",
+		"Invalid payee name":                                       "Nama penerima tidak valid",
+		"Payee alias deleted":                                      "Alias penerima dihapus",
+		"Payee alias not found":                                    "Alias penerima tidak ditemukan",
+		"Payee deleted":                                            "Penerima dihapus",
+		"Payee not found":                                          "Penerima tidak ditemukan",
+		"Payee or alias already exists":                            "Penerima atau alias sudah ada",
+		"Failed to fetch anomalies":                                "Gagal mengambil anomali",
+		"Anomaly not found":                                        "Anomali tidak ditemukan",
+		"Failed to dismiss anomaly":                                "Gagal mengabaikan anomali",
+		"Envelope budgeting is not enabled":                        "Penganggaran amplop belum diaktifkan",
+		"Failed to fetch envelopes":                                "Gagal mengambil amplop",
+		"Failed to fetch envelope ledger":                          "Gagal mengambil buku besar amplop",
+		"Failed to assign money":                                   "Gagal mengalokasikan uang",
+		"Failed to move money":                                     "Gagal memindahkan uang",
+		"Amount must be positive":                                  "Jumlah harus positif",
+		"Cannot move money to the same envelope":                   "Tidak dapat memindahkan uang ke amplop yang sama",
+		"Note is too long":                                         "Catatan terlalu panjang",
+		"Failed to fetch budget templates":                         "Gagal mengambil templat anggaran",
+		"Failed to create budget template":                         "Gagal membuat templat anggaran",
+		"Failed to update budget template":                         "Gagal memperbarui templat anggaran",
+		"Failed to delete budget template":                         "Gagal menghapus templat anggaran",
+		"Budget template not found":                                "Templat anggaran tidak ditemukan",
+		"Budget template deleted":                                  "Templat anggaran dihapus",
+		"Failed to apply budget template":                          "Gagal menerapkan templat anggaran",
+		"Invalid date, expected YYYY-MM-DD":                        "Tanggal tidak valid, gunakan format YYYY-MM-DD",
+		"Failed to fetch budget variance report":                   "Gagal mengambil laporan selisih anggaran",
+		"You cannot disable your own account":                      "Anda tidak dapat menonaktifkan akun Anda sendiri",
+		"Too many requests, please try again later":                "Terlalu banyak permintaan, silakan coba lagi nanti",
+		"Transaction deleted":                                      "Transaksi dihapus",
+		"Transaction restored":                                     "Transaksi dipulihkan",
+		"User registered successfully":                             "Pengguna berhasil didaftarkan",
+		"Email address not verified":                               "Alamat email belum diverifikasi",
+		"Email already verified":                                   "Email sudah diverifikasi",
+		"Email verified":                                           "Email berhasil diverifikasi",
+		"Failed to reset password":                                 "Gagal mengatur ulang kata sandi",
+		"Failed to send verification email":                        "Gagal mengirim email verifikasi",
+		"Failed to verify email":                                   "Gagal memverifikasi email",
+		"If the email is registered, a reset link has been sent":   "Jika email terdaftar, tautan atur ulang telah dikirim",
+		"Invalid or expired token":                                 "Token tidak valid atau sudah kedaluwarsa",
+		"Password has been reset":                                  "Kata sandi berhasil diatur ulang",
+		"Failed to disable two-factor authentication":              "Gagal menonaktifkan autentikasi dua faktor",
+		"Failed to enable two-factor authentication":               "Gagal mengaktifkan autentikasi dua faktor",
+		"Failed to generate recovery codes":                        "Gagal membuat kode pemulihan",
+		"Failed to generate secret":                                "Gagal membuat kunci rahasia",
+		"Failed to verify two-factor code":                         "Gagal memverifikasi kode dua faktor",
+		"Invalid credentials":                                      "Kredensial tidak valid",
+		"Invalid two-factor code":                                  "Kode dua faktor tidak valid",
+		"Two-factor authentication disabled":                       "Autentikasi dua faktor dinonaktifkan",
+		"Two-factor authentication enabled":                        "Autentikasi dua faktor diaktifkan",
+		"Two-factor authentication is already enabled":             "Autentikasi dua faktor sudah aktif",
+		"Two-factor authentication is not enabled":                 "Autentikasi dua faktor belum aktif",
+		"Two-factor enrollment has not been started":               "Pendaftaran dua faktor belum dimulai",
+		"Verification email sent":                                  "Email verifikasi telah dikirim",
+	},
+}
+
+// isSupportedLocale reports whether the message catalog has the given locale
+func isSupportedLocale(locale string) bool {
+	_, ok := messageCatalog[locale]
+	return ok
+}
+
+// parseAcceptLanguage returns the base language tags of an Accept-Language header, best match first
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		// Only the base language matters for the catalog ("id-ID" -> "id")
+		tag, _, _ = strings.Cut(tag, "-")
+		tags = append(tags, weighted{tag: tag, q: q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	result := make([]string, 0, len(tags))
+	for _, t := range tags {
+		result = append(result, t.tag)
+	}
+	return result
+}
+
+// requestLocale picks the locale for a request from Accept-Language, then the user's settings
+func requestLocale(c *gin.Context) string {
+	for _, tag := range parseAcceptLanguage(c.GetHeader("Accept-Language")) {
+		if isSupportedLocale(tag) {
+			return tag
+		}
+	}
+
+	if userID, exists := c.Get("userID"); exists {
+		if settings := loadUserSettings(userID.(uint)); isSupportedLocale(settings.Locale) {
+			return settings.Locale
+		}
+	}
+
+	return defaultLocale
+}
+
+// T translates an English API message into the locale of the request
+func T(c *gin.Context, message string) string {
+	if translated, ok := messageCatalog[requestLocale(c)][message]; ok {
+		return translated
+	}
+	return message
+}
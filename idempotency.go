@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxIdempotencyKeyLength matches the size of IdempotencyKey.Key
+const maxIdempotencyKeyLength = 255
+
+// idempotencyKeyTTL is how long a stored response can be replayed
+func idempotencyKeyTTL() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("IDEMPOTENCY_KEY_TTL_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = 24
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// responseRecorder passes a response through while keeping a copy of its body
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotent makes a create endpoint safe to retry. When the request has an Idempotency-Key
+// header, the first response is stored and replayed for later requests with the same key; reusing
+// a key for a different request is rejected with 409. Use it after AuthMiddleware.
+func Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Idempotency key is too long")})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Invalid request")})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// The hash covers the route too, so a key cannot be replayed against another endpoint
+		requestHash := hashToken(c.Request.Method + " " + c.FullPath() + "\n" + string(body))
+		record, claimed, err := claimIdempotencyKey(c.GetUint("userID"), key, requestHash)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to process idempotency key")})
+			c.Abort()
+			return
+		}
+
+		if !claimed {
+			replayIdempotentResponse(c, record, requestHash)
+			return
+		}
+
+		// Release the reservation unless a final response gets stored, so that a handler that
+		// panics or writes nothing does not leave the key "in progress" until it expires
+		stored := false
+		defer func() {
+			if !stored {
+				DB.Delete(&IdempotencyKey{}, record.ID)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Server errors are not replayed, so the client can retry them with the same key
+		status := recorder.Status()
+		if !recorder.Written() || status == 0 || status >= http.StatusInternalServerError {
+			return
+		}
+		err = DB.Model(&IdempotencyKey{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+			"status":   status,
+			"response": recorder.body.Bytes(),
+			"etag":     recorder.Header().Get("ETag"),
+		}).Error
+		if err != nil {
+			log.Printf("Failed to store response for idempotency key %d: %v", record.ID, err)
+			return
+		}
+		stored = true
+	}
+}
+
+// claimIdempotencyKey reserves the key for this request. It returns claimed=false with the existing
+// record when another request already used the key; an expired record is replaced.
+func claimIdempotencyKey(userID uint, key, requestHash string) (IdempotencyKey, bool, error) {
+	record := IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		RequestHash: requestHash,
+		ExpiresAt:   time.Now().Add(idempotencyKeyTTL()),
+	}
+
+	// The unique index on (user_id, key) makes concurrent retries race safely: only one insert wins
+	for attempt := 0; attempt < 2; attempt++ {
+		result := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			return record, false, result.Error
+		}
+		if result.RowsAffected == 1 {
+			return record, true, nil
+		}
+
+		var existing IdempotencyKey
+		if err := DB.Where("user_id = ? AND key = ?", userID, key).First(&existing).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue // Deleted after a server error; try to claim it again
+			}
+			return record, false, err
+		}
+		if existing.ExpiresAt.After(time.Now()) {
+			return existing, false, nil
+		}
+		if err := DB.Delete(&existing).Error; err != nil {
+			return record, false, err
+		}
+		record.ID = 0
+	}
+	return record, false, errors.New("could not claim idempotency key")
+}
+
+// replayIdempotentResponse answers a retried request from the stored record
+func replayIdempotentResponse(c *gin.Context, record IdempotencyKey, requestHash string) {
+	defer c.Abort()
+
+	if record.RequestHash != requestHash {
+		c.JSON(http.StatusConflict, gin.H{"error": T(c, "Idempotency key was already used for a different request")})
+		return
+	}
+	if record.Status == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": T(c, "A request with this idempotency key is still in progress")})
+		return
+	}
+
+	if record.ETag != "" {
+		c.Header("ETag", record.ETag)
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(record.Status, "application/json; charset=utf-8", record.Response)
+}
+
+// purgeExpiredIdempotencyKeys deletes stored responses whose replay window has passed
+func purgeExpiredIdempotencyKeys() {
+	if err := DB.Where("expires_at < ?", time.Now()).Delete(&IdempotencyKey{}).Error; err != nil {
+		log.Println("Failed to purge expired idempotency keys:", err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// idempotentRouter serves handler behind Idempotent for a fixed user
+func idempotentRouter(handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(gin.Recovery(), func(c *gin.Context) { c.Set("userID", uint(1)) })
+	router.POST("/things", Idempotent(), handler)
+	return router
+}
+
+// postWithKey sends a POST /things with the given Idempotency-Key
+func postWithKey(router *gin.Engine, key string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(`{"name":"a"}`))
+	request.Header.Set("Idempotency-Key", key)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func TestIdempotentReplaysStoredResponse(t *testing.T) {
+	setupTestDB(t, &IdempotencyKey{})
+
+	calls := 0
+	router := idempotentRouter(func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"call": calls})
+	})
+
+	first := postWithKey(router, "replay")
+	second := postWithKey(router, "replay")
+	if calls != 1 || second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Fatalf("calls = %d, replay %d %s; want one call and the first response replayed", calls, second.Code, second.Body.String())
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replayed response is not marked as such")
+	}
+}
+
+func TestIdempotentReleasesKeyWithoutFinalResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+	}{
+		{"panic", func(c *gin.Context) { panic("boom") }},
+		{"no response", func(c *gin.Context) {}},
+		{"server error", func(c *gin.Context) { c.JSON(http.StatusInternalServerError, gin.H{}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t, &IdempotencyKey{})
+
+			postWithKey(idempotentRouter(tt.handler), "retry")
+
+			var reserved int64
+			DB.Model(&IdempotencyKey{}).Count(&reserved)
+			if reserved != 0 {
+				t.Fatalf("%d reservations left behind, want the key released", reserved)
+			}
+
+			// A retry with the same key runs the handler instead of reporting it in progress
+			retry := postWithKey(idempotentRouter(func(c *gin.Context) { c.JSON(http.StatusCreated, gin.H{}) }), "retry")
+			if retry.Code != http.StatusCreated {
+				t.Fatalf("retry status %d, body %s; want 201", retry.Code, retry.Body.String())
+			}
+		})
+	}
+}
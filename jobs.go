@@ -0,0 +1,37 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// backgroundJob is a maintenance task run periodically for the lifetime of the server
+type backgroundJob struct {
+	name     string
+	interval time.Duration
+	run      func()
+}
+
+// backgroundJobs lists the periodic maintenance tasks started by StartBackgroundJobs
+var backgroundJobs = []backgroundJob{
+	{name: "purge deleted accounts", interval: time.Hour, run: purgeDeletedAccounts},
+	{name: "purge expired trash", interval: time.Hour, run: purgeExpiredTrash},
+	{name: "purge expired idempotency keys", interval: time.Hour, run: purgeExpiredIdempotencyKeys},
+	{name: "rebuild category classifiers", interval: 24 * time.Hour, run: rebuildClassifiers},
+}
+
+// StartBackgroundJobs runs every background job once and then on its interval
+func StartBackgroundJobs() {
+	for _, job := range backgroundJobs {
+		go func(job backgroundJob) {
+			ticker := time.NewTicker(job.interval)
+			defer ticker.Stop()
+
+			for {
+				log.Println("Running background job:", job.name)
+				job.run()
+				<-ticker.C
+			}
+		}(job)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingKey is one key in the key ring; retired keys have no private half and only verify
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// KeyRing holds the key that signs new JWTs and every key that still verifies old ones,
+// so keys can rotate without logging everyone out
+type KeyRing struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+// AppKeys is the key ring used to sign and verify JWTs, configured in main
+var AppKeys *KeyRing
+
+// LoadKeyRing loads the PEM keys in JWT_KEYS_DIR, where each file name (minus ".pem") is the key ID.
+// JWT_ACTIVE_KID picks the signing key; it may be omitted when there is only one private key.
+// In development an ephemeral key is generated when no directory is configured.
+func LoadKeyRing() (*KeyRing, error) {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		if !isDevelopment() {
+			return nil, errors.New("JWT_KEYS_DIR must be set outside development mode")
+		}
+		return newEphemeralKeyRing()
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	ring := &KeyRing{keys: make(map[string]*signingKey)}
+	var privateKIDs []string
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		key, err := loadPEMKey(kid, file)
+		if err != nil {
+			return nil, fmt.Errorf("loading key %s: %w", file, err)
+		}
+		ring.keys[kid] = key
+		if key.private != nil {
+			privateKIDs = append(privateKIDs, kid)
+		}
+	}
+
+	activeKID := os.Getenv("JWT_ACTIVE_KID")
+	if activeKID == "" && len(privateKIDs) == 1 {
+		activeKID = privateKIDs[0]
+	}
+	active, ok := ring.keys[activeKID]
+	if !ok || active.private == nil {
+		return nil, fmt.Errorf("no private key for active key ID %q in %s", activeKID, dir)
+	}
+	ring.active = active
+	return ring, nil
+}
+
+// newEphemeralKeyRing generates a throwaway Ed25519 key; sessions end when the server restarts
+func newEphemeralKeyRing() (*KeyRing, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	suffix, err := randomToken(4)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{kid: "dev-" + suffix, method: jwt.SigningMethodEdDSA, private: private, public: public}
+	return &KeyRing{active: key, keys: map[string]*signingKey{key.kid: key}}, nil
+}
+
+// loadPEMKey parses an RSA or Ed25519 key from a PEM file, either private (signs) or public (verifies only)
+func loadPEMKey(kid, file string) (*signingKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{kid: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T (use RSA or Ed25519)", parsed)
+	}
+	return key, nil
+}
+
+// Sign signs the claims with the active key and records its key ID in the header
+func (r *KeyRing) Sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(r.active.method, claims)
+	token.Header["kid"] = r.active.kid
+	return token.SignedString(r.active.private)
+}
+
+// Keyfunc finds the verification key named by the token's "kid" header
+func (r *KeyRing) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := r.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	// Ensure the token was signed with the algorithm that belongs to this key
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// JWKS returns the public half of every verification key
+func (r *KeyRing) JWKS() JWKS {
+	kids := make([]string, 0, len(r.keys))
+	for kid := range r.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	encode := base64.RawURLEncoding.EncodeToString
+	jwks := JWKS{Keys: []JWK{}}
+	for _, kid := range kids {
+		key := r.keys[kid]
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "RSA", Kid: kid, Use: "sig", Alg: key.method.Alg(),
+				N: encode(public.N.Bytes()),
+				E: encode(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "OKP", Kid: kid, Use: "sig", Alg: key.method.Alg(),
+				Crv: "Ed25519",
+				X:   encode(public),
+			})
+		}
+	}
+	return jwks
+}
+
+// GetJWKS publishes the public keys that verify this server's JWTs
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, AppKeys.JWKS())
+}
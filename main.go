@@ -14,12 +14,28 @@ func main() {
 		log.Println("Warning: .env file not found, using default environment variables")
 	}
 
+	// Load the keys that sign and verify JWTs; refuse to start without them outside development
+	keys, err := LoadKeyRing()
+	if err != nil {
+		log.Fatal("Failed to load JWT signing keys: ", err)
+	}
+	AppKeys = keys
+
 	// Initialize database connection and run migrations
 	InitDatabase()
 
+	// Configure how emails (verification, password reset) are delivered
+	AppMailer = NewMailerFromEnv()
+
+	// Load the OpenID Connect providers available for social login
+	OIDCProviders = LoadOIDCProviders()
+
 	// Seed the database with initial data (only in development mode)
 	SeedDatabase()
 
+	// Start periodic maintenance such as purging deleted accounts
+	StartBackgroundJobs()
+
 	// Set up the HTTP router
 	router := SetupRouter()
 
@@ -33,3 +49,8 @@ func main() {
 	log.Println("Server running on port", port)
 	router.Run(":" + port)
 }
+
+// isDevelopment reports whether the server runs in development mode (APP_ENV=development)
+func isDevelopment() bool {
+	return os.Getenv("APP_ENV") == "development"
+}
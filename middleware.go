@@ -10,9 +10,6 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// Define the secret key for signing JWT tokens
-var jwtSecret = []byte(secretKey)
-
 // AuthMiddleware is a middleware function for JWT authentication
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -33,26 +30,42 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		// If no token is found, return an unauthorized response
 		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token missing"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Authorization token missing")})
 			c.Abort()
 			return
 		}
 
-		// Create a map to store JWT claims
-		claims := jwt.MapClaims{}
+		// Personal access tokens are looked up by hash and carry their own scopes
+		if isPersonalToken(tokenString) {
+			token := authenticatePersonalToken(tokenString)
+			if token == nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid token")})
+				c.Abort()
+				return
+			}
 
-		// Parse and validate the JWT token
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			// Ensure the signing method is HMAC
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			if !setAuthenticatedUser(c, token.UserID) {
+				return
 			}
-			return jwtSecret, nil
-		}, jwt.WithValidMethods([]string{"HS256"}))
+
+			c.Set("tokenScopes", []string(token.Scopes))
+			c.Next()
+			return
+		}
+
+		// Parse and validate the JWT token
+		claims, err := parseToken(tokenString)
 
 		// If token is invalid, return an unauthorized response
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token", "details": err.Error()})
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid token"), "details": err.Error()})
+			c.Abort()
+			return
+		}
+
+		// Purpose-bound tokens (e.g. a pending two-factor login) are not session tokens
+		if purpose, _ := claims["purpose"].(string); purpose != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid token")})
 			c.Abort()
 			return
 		}
@@ -60,14 +73,14 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Check if the token has an expiration claim
 		exp, ok := claims["exp"].(float64)
 		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token expiration (exp) missing"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Token expiration (exp) missing")})
 			c.Abort()
 			return
 		}
 
 		// Check if the token has expired
 		if time.Now().Unix() > int64(exp) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token expired"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Token expired")})
 			c.Abort()
 			return
 		}
@@ -75,16 +88,66 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Extract user ID from the token claims
 		userIDFloat, ok := claims["user_id"].(float64)
 		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid user ID in token")})
 			c.Abort()
 			return
 		}
 
-		// Convert user ID to uint and set it in the request context
-		userID := uint(userIDFloat)
-		c.Set("userID", userID)
+		// Convert user ID to uint and set it (with the user's role) in the request context
+		if !setAuthenticatedUser(c, uint(userIDFloat)) {
+			return
+		}
 
 		// Proceed to the next middleware or handler
 		c.Next()
 	}
 }
+
+// setAuthenticatedUser checks that the account still exists and is not disabled, then stores
+// its ID and role in the request context. It aborts the request and returns false otherwise.
+func setAuthenticatedUser(c *gin.Context, userID uint) bool {
+	var user User
+	if err := DB.Select("id", "role", "disabled_at").First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "User not found")})
+		c.Abort()
+		return false
+	}
+
+	if user.DisabledAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": T(c, "Account disabled")})
+		c.Abort()
+		return false
+	}
+
+	c.Set("userID", user.ID)
+	c.Set("userRole", user.Role)
+	return true
+}
+
+// RequireRole restricts a route group to users with the given role; use it after AuthMiddleware
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("userRole") != role {
+			c.JSON(http.StatusForbidden, gin.H{"error": T(c, "Forbidden")})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// parseToken verifies a JWT's signature and standard claims and returns its claims
+func parseToken(tokenString string) (jwt.MapClaims, error) {
+	// Create a map to store JWT claims
+	claims := jwt.MapClaims{}
+
+	// The key ID in the header selects the verification key, so rotated keys keep working
+	token, err := jwt.ParseWithClaims(tokenString, claims, AppKeys.Keyfunc, jwt.WithValidMethods([]string{"RS256", "EdDSA"}))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("token is not valid")
+	}
+	return claims, nil
+}
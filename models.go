@@ -1,6 +1,8 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -10,15 +12,29 @@ import (
 // Global variable to hold the database connection
 var DB *gorm.DB
 
+// User roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 // User model representing a user in the system
 type User struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Name      string         `json:"name"`
-	Email     string         `gorm:"unique;not null" json:"email"`
-	Password  string         `json:"-"` // The password is excluded from JSON responses
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"` // Soft delete field
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	Name            string         `json:"name"`
+	Email           string         `gorm:"unique;not null" json:"email"`
+	Password        string         `json:"-"`                                 // The password is excluded from JSON responses
+	Role            string         `gorm:"not null;default:user" json:"role"` // "user" or "admin"
+	DisabledAt      *time.Time     `json:"disabled_at"`                       // Set when an admin disables the account
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`                 // Set once the user confirms their address
+	TOTPSecret      string         `json:"-"`                                 // Base32 TOTP secret, pending until TOTPEnabled
+	TOTPEnabled     bool           `gorm:"not null;default:false" json:"totp_enabled"`
+	TOTPLastCounter int64          `json:"-"`                           // Last accepted time step, so a code cannot be replayed
+	FailedLogins    int            `gorm:"not null;default:0" json:"-"` // Consecutive failed login attempts
+	LockedUntil     *time.Time     `json:"-"`                           // Set after too many failed logins
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at"` // Soft delete field
 }
 
 // Category model representing a transaction category
@@ -35,28 +51,239 @@ type Budget struct {
 	Category     Category       `gorm:"foreignKey:CategoryID" json:"category"`
 	Amount       float64        `gorm:"not null" json:"amount"`
 	Currency     string         `gorm:"not null" json:"currency"`
-	ExchangeRate float64        `gorm:"not null" json:"exchange_rate"`         // Exchange rate to IDR
-	Spent        float64        `gorm:"-" json:"spent"`                        // Calculated field (not stored in DB)
-	Month        string         `gorm:"type:varchar(7);not null" json:"month"` // Format: "YYYY-MM"
+	ExchangeRate float64        `gorm:"not null" json:"exchange_rate"`                           // Exchange rate to IDR
+	Percent      *float64       `json:"percent"`                                                 // Share of the month's income; when set, Amount (in IDR) follows the income
+	Spent        float64        `gorm:"-" json:"spent"`                                          // Calculated field (not stored in DB)
+	Month        string         `gorm:"type:varchar(7);not null" json:"month"`                   // Format: "YYYY-MM"; the month the period starts in
+	PeriodType   string         `gorm:"type:varchar(10);not null;default:monthly" json:"period"` // "weekly", "monthly", "quarterly", "yearly" or "custom"
+	StartDate    string         `gorm:"type:varchar(10);not null;index" json:"start_date"`       // Format: "YYYY-MM-DD"
+	EndDate      string         `gorm:"type:varchar(10);not null;index" json:"end_date"`         // Inclusive, format: "YYYY-MM-DD"
+	Version      uint           `gorm:"not null;default:1" json:"version"`                       // Incremented on every change
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"deleted_at"` // Soft delete field
 }
 
+// BudgetTemplate is a reusable set of budget lines, e.g. a 50/30/20 split of income
+type BudgetTemplate struct {
+	ID        uint                 `gorm:"primaryKey" json:"id"`
+	UserID    uint                 `gorm:"not null;uniqueIndex:idx_budget_template_user_name" json:"user_id"`
+	Name      string               `gorm:"type:varchar(100);not null;uniqueIndex:idx_budget_template_user_name" json:"name"`
+	Lines     []BudgetTemplateLine `gorm:"serializer:json;not null" json:"lines"`
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+}
+
+// BudgetTemplateLine budgets one category, either a fixed amount or a percentage of the month's income
+type BudgetTemplateLine struct {
+	CategoryID   uint    `json:"category_id"`
+	Amount       float64 `json:"amount,omitempty"` // Fixed limit, in Currency
+	Currency     string  `json:"currency,omitempty"`
+	ExchangeRate float64 `json:"exchange_rate,omitempty"` // Exchange rate to IDR
+	Percent      float64 `json:"percent,omitempty"`       // Share of income, 0 to 100; used instead of Amount when set
+}
+
+// EnvelopeAssignment is one entry of the envelope budgeting ledger: money assigned to (or, when
+// negative, taken out of) a category envelope for a month
+type EnvelopeAssignment struct {
+	ID                    uint      `gorm:"primaryKey" json:"id"`
+	UserID                uint      `gorm:"not null;index:idx_envelope_assignment_user_month" json:"user_id"`
+	CategoryID            uint      `gorm:"not null" json:"category_id"`
+	Category              Category  `gorm:"foreignKey:CategoryID" json:"category"`
+	CounterpartCategoryID *uint     `json:"counterpart_category_id"`                                                        // Set on moves: the envelope on the other side
+	Amount                float64   `gorm:"not null" json:"amount"`                                                         // In IDR
+	Month                 string    `gorm:"type:varchar(7);not null;index:idx_envelope_assignment_user_month" json:"month"` // Format: "YYYY-MM"
+	Note                  string    `gorm:"type:varchar(255)" json:"note"`
+	CreatedAt             time.Time `json:"created_at"`
+}
+
 // Transaction model representing income and expenses
 type Transaction struct {
-	ID           uint           `gorm:"primaryKey" json:"id"`
-	Type         string         `gorm:"not null" json:"type"` // "Income" or "Expense"
-	Amount       float64        `gorm:"not null" json:"amount"`
-	Currency     string         `gorm:"not null" json:"currency"`
-	ExchangeRate float64        `gorm:"not null" json:"exchange_rate"` // Exchange rate to IDR
-	Note         string         `json:"note"`
-	CategoryID   *uint          `json:"category_id"` // Nullable category ID
-	Category     Category       `gorm:"foreignKey:CategoryID" json:"category"`
-	UserID       uint           `gorm:"not null" json:"user_id"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"deleted_at"` // Soft delete field
+	ID           uint                 `gorm:"primaryKey" json:"id"`
+	Type         string               `gorm:"not null" json:"type"` // "Income" or "Expense"
+	Amount       float64              `gorm:"not null" json:"amount"`
+	Currency     string               `gorm:"not null" json:"currency"`
+	ExchangeRate float64              `gorm:"not null" json:"exchange_rate"` // Exchange rate to IDR
+	Note         string               `json:"note"`
+	CategoryID   *uint                `json:"category_id"` // Nullable category ID
+	Category     Category             `gorm:"foreignKey:CategoryID" json:"category"`
+	Tags         []Tag                `gorm:"many2many:transaction_tag" json:"tags"`
+	PayeeID      *uint                `gorm:"index" json:"payee_id"` // Nullable canonical merchant or payer
+	Payee        *Payee               `gorm:"foreignKey:PayeeID" json:"payee,omitempty"`
+	Anomalies    []TransactionAnomaly `gorm:"foreignKey:TransactionID" json:"anomalies,omitempty"`
+	UserID       uint                 `gorm:"not null" json:"user_id"`
+	OccurredAt   time.Time            `gorm:"index" json:"occurred_at"`          // When the money actually moved
+	Version      uint                 `gorm:"not null;default:1" json:"version"` // Incremented on every change
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt       `gorm:"index" json:"deleted_at"` // Soft delete field
+}
+
+// Tag is a user-defined label; a transaction can carry any number of tags
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_tag_user_name" json:"user_id"`
+	Name      string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_tag_user_name" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Payee is a canonical merchant or payer that raw bank descriptions are normalized to
+type Payee struct {
+	ID        uint         `gorm:"primaryKey" json:"id"`
+	UserID    uint         `gorm:"not null;uniqueIndex:idx_payee_user_name" json:"user_id"`
+	Name      string       `gorm:"type:varchar(100);not null;uniqueIndex:idx_payee_user_name" json:"name"`
+	Aliases   []PayeeAlias `gorm:"foreignKey:PayeeID" json:"aliases,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// TransactionAnomaly flags a transaction that looks unusual compared with the user's own history
+type TransactionAnomaly struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        uint       `gorm:"not null;index" json:"user_id"`
+	TransactionID uint       `gorm:"not null;uniqueIndex:idx_transaction_anomaly_kind" json:"transaction_id"`
+	Kind          string     `gorm:"type:varchar(32);not null;uniqueIndex:idx_transaction_anomaly_kind" json:"kind"` // "amount_outlier", "duplicate" or "new_payee_large"
+	Score         float64    `gorm:"not null" json:"score"`                                                          // How unusual; the meaning depends on the kind
+	Details       string     `json:"details"`
+	DismissedAt   *time.Time `json:"dismissed_at"` // Set once the user has reviewed the flag
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// PayeeAlias maps a normalized raw description (e.g. "indomaret jakarta") to a payee
+type PayeeAlias struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_payee_alias_user_pattern" json:"user_id"`
+	PayeeID   uint      `gorm:"not null;index" json:"payee_id"`
+	Pattern   string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_payee_alias_user_pattern" json:"pattern"` // Stored normalized
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserSettings stores per-user preferences applied to reports and API messages
+type UserSettings struct {
+	UserID            uint      `gorm:"primaryKey" json:"user_id"`
+	Timezone          string    `gorm:"not null;default:UTC" json:"timezone"` // IANA name, e.g. "Asia/Jakarta"
+	Locale            string    `gorm:"not null;default:en" json:"locale"`    // Message catalog locale, e.g. "id"
+	AnomalyAlerts     bool      `gorm:"not null" json:"anomaly_alerts"`       // Email the user when a transaction is flagged
+	EnvelopeBudgeting bool      `gorm:"not null" json:"envelope_budgeting"`   // Opt-in zero-based budgeting with envelopes
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// UserToken is a single-use, expiring token sent to a user by email
+type UserToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Purpose   string     `gorm:"not null" json:"purpose"`       // "verify_email" or "reset_password"
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"` // SHA-256 of the emailed token
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"` // Set when the token is consumed
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RecoveryCode is a hashed one-time code that can replace a TOTP code during login
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"not null" json:"-"` // SHA-256 of the normalized code
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// UserIdentity links a user to an account at an external OpenID Connect provider
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Provider  string    `gorm:"not null;uniqueIndex:idx_identity_provider_subject" json:"provider"`
+	Subject   string    `gorm:"not null;uniqueIndex:idx_identity_provider_subject" json:"subject"` // The provider's "sub" claim
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PersonalAccessToken is a named, scoped API token for scripts and integrations
+type PersonalAccessToken struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Name       string     `gorm:"not null" json:"name"`
+	Prefix     string     `gorm:"not null" json:"prefix"`                 // First characters of the token, to tell tokens apart
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`          // SHA-256 of the token
+	Scopes     []string   `gorm:"serializer:json;not null" json:"scopes"` // e.g. ["read:transactions"]
+	ExpiresAt  *time.Time `json:"expires_at"`                             // Nil means the token never expires
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ExchangeRate is the system-wide conversion rate from a currency to IDR, managed by admins
+type ExchangeRate struct {
+	Currency  string    `gorm:"primaryKey;type:varchar(3)" json:"currency"` // ISO 4217 code, e.g. "USD"
+	RateToIDR float64   `gorm:"not null" json:"rate_to_idr"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AuditLog is an append-only record of a data change: who did what to which entity, and from where
+type AuditLog struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	ActorID    uint            `gorm:"not null;index" json:"actor_id"` // 0 for unauthenticated or system changes
+	Action     string          `gorm:"not null" json:"action"`         // "create", "update", "delete", "restore", ...
+	EntityType string          `gorm:"not null;index:idx_audit_entity" json:"entity_type"`
+	EntityID   string          `gorm:"not null;index:idx_audit_entity" json:"entity_id"`
+	Version    uint            `json:"version"`                   // Entity version after the change, for versioned entities
+	Before     json.RawMessage `gorm:"type:jsonb" json:"before"`  // Entity state before the change
+	After      json.RawMessage `gorm:"type:jsonb" json:"after"`   // Entity state after the change
+	Changes    json.RawMessage `gorm:"type:jsonb" json:"changes"` // Changed fields with their before/after values
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"user_agent"`
+	CreatedAt  time.Time       `gorm:"index" json:"created_at"`
+}
+
+// Rule categorizes and tags transactions automatically when all of its conditions match
+type Rule struct {
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	UserID         uint            `gorm:"not null;index" json:"user_id"`
+	Name           string          `gorm:"not null" json:"name"`
+	Priority       int             `gorm:"not null;default:0" json:"priority"` // Lower runs first
+	Enabled        bool            `gorm:"not null" json:"enabled"`
+	Conditions     []RuleCondition `gorm:"serializer:json;not null" json:"conditions"`
+	CategoryID     *uint           `json:"category_id"`                                   // Category to assign, if any
+	Tags           []string        `gorm:"serializer:json;not null" json:"tags"`          // Tag names to add
+	StopProcessing bool            `gorm:"not null;default:false" json:"stop_processing"` // Skip lower-priority rules after a match
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// ClassifierCategoryCount is the per-category total of the category suggestion model:
+// how many of the user's transactions were filed under the category, and their token count
+type ClassifierCategoryCount struct {
+	UserID     uint `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	CategoryID uint `gorm:"primaryKey;autoIncrement:false" json:"category_id"`
+	Documents  int  `gorm:"not null" json:"documents"`
+	Tokens     int  `gorm:"not null" json:"tokens"`
+}
+
+// ClassifierTokenCount counts how often a token appeared in the user's transactions of a category
+type ClassifierTokenCount struct {
+	UserID     uint   `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	CategoryID uint   `gorm:"primaryKey;autoIncrement:false" json:"category_id"`
+	Token      string `gorm:"primaryKey;type:varchar(64)" json:"token"`
+	Count      int    `gorm:"not null" json:"count"`
+}
+
+// IdempotencyKey remembers the response to a create request so a retry with the same
+// Idempotency-Key header gets that response again instead of creating a duplicate
+type IdempotencyKey struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_idempotency_user_key" json:"user_id"`
+	Key         string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_idempotency_user_key" json:"key"`
+	RequestHash string    `gorm:"not null" json:"-"` // SHA-256 of the method, path and body
+	Status      int       `json:"status"`            // Zero while the first request is still running
+	Response    []byte    `json:"-"`                 // Response body to replay
+	ETag        string    `gorm:"column:etag" json:"-"`
+	ExpiresAt   time.Time `gorm:"index;not null" json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// BeforeUpdate keeps the audit log append-only
+func (AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return errors.New("audit log entries cannot be modified")
 }
 
 // HashPassword hashes the user's password before storing it in the database
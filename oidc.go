@@ -0,0 +1,463 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// oidcStateCookie holds the signed state, nonce and PKCE verifier between login and callback
+const oidcStateCookie = "oidc_state"
+
+// oidcHTTPClient is used for discovery, token and JWKS requests to identity providers
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// OIDCProvider is an OpenID Connect identity provider configured through environment variables
+type OIDCProvider struct {
+	Name         string
+	DiscoveryURL string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+}
+
+// oidcDiscovery is the subset of the provider's discovery document that the login flow needs
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// JWK is a JSON Web Key as published in a JWKS document
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // Curve name for EC and OKP keys
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// OIDCProviders holds the providers listed in OIDC_PROVIDERS, keyed by name; configured in main
+var OIDCProviders = map[string]*OIDCProvider{}
+
+// LoadOIDCProviders reads OIDC_PROVIDERS (comma-separated names) and each OIDC_<NAME>_* setting
+func LoadOIDCProviders() map[string]*OIDCProvider {
+	providers := make(map[string]*OIDCProvider)
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := &OIDCProvider{
+			Name:         name,
+			DiscoveryURL: os.Getenv(prefix + "DISCOVERY_URL"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+		}
+		if provider.DiscoveryURL == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			log.Printf("Warning: OIDC provider %q is missing DISCOVERY_URL, CLIENT_ID or REDIRECT_URL and was skipped", name)
+			continue
+		}
+		providers[name] = provider
+	}
+	return providers
+}
+
+// getJSON fetches a URL and decodes its JSON body into v
+func getJSON(rawURL string, v interface{}) error {
+	resp, err := oidcHTTPClient.Get(rawURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", rawURL, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Discover returns the provider's discovery document, fetching it on first use.
+// The discovery URL may be the issuer itself or its /.well-known/openid-configuration.
+func (p *OIDCProvider) Discover() (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	discoveryURL := p.DiscoveryURL
+	if !strings.HasSuffix(discoveryURL, "/.well-known/openid-configuration") {
+		discoveryURL = strings.TrimSuffix(discoveryURL, "/") + "/.well-known/openid-configuration"
+	}
+
+	var discovery oidcDiscovery
+	if err := getJSON(discoveryURL, &discovery); err != nil {
+		return nil, err
+	}
+	if discovery.Issuer == "" || discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("incomplete OIDC discovery document")
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// exchangeCode trades an authorization code (and its PKCE verifier) for an ID token
+func (p *OIDCProvider) exchangeCode(discovery *oidcDiscovery, code, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", verifier)
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	resp, err := oidcHTTPClient.PostForm(discovery.TokenEndpoint, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return "", fmt.Errorf("token exchange failed: %s %s", body.Error, body.ErrorDescription)
+	}
+	return body.IDToken, nil
+}
+
+// publicKey converts a JWK into the crypto public key used to verify signatures
+func (k JWK) publicKey() (interface{}, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported EC curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// verifyIDToken checks the ID token's signature against the provider's JWKS and validates
+// issuer, audience, expiry and nonce
+func (p *OIDCProvider) verifyIDToken(discovery *oidcDiscovery, idToken, nonce string) (jwt.MapClaims, error) {
+	var jwks JWKS
+	if err := getJSON(discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		for _, key := range jwks.Keys {
+			// A provider with a single key may omit the key ID
+			if key.Kid == kid || (kid == "" && len(jwks.Keys) == 1) {
+				return key.publicKey()
+			}
+		}
+		return nil, fmt.Errorf("no JWKS key matches kid %q", kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return nil, errors.New("ID token nonce mismatch")
+	}
+	return claims, nil
+}
+
+// pkceChallenge derives the S256 code challenge from a PKCE verifier
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// setOIDCStateCookie stores (or clears, when value is empty) the signed login state
+func setOIDCStateCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/auth/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode, // Sent on the top-level redirect back from the provider
+	})
+}
+
+// AuditEntityUserIdentity identifies linked identity provider accounts in the audit log
+const AuditEntityUserIdentity = "user_identity"
+
+// errOIDCAccountUnverified means an account with the provider's email exists but its owner never
+// verified that address, so the provider's claim to it cannot be trusted to take the account over
+var errOIDCAccountUnverified = errors.New("existing account has not verified its email")
+
+// linkOIDCIdentity finds or creates the user behind an external identity. Unknown identities are
+// linked to an existing account only when both the provider and the account have verified the email.
+func linkOIDCIdentity(c *gin.Context, provider string, claims jwt.MapClaims) (*User, error) {
+	subject, _ := claims["sub"].(string)
+	email, _ := claims["email"].(string)
+	name, _ := claims["name"].(string)
+	if subject == "" {
+		return nil, errors.New("ID token has no subject")
+	}
+
+	// Some providers send email_verified as a string
+	verified := claims["email_verified"] == true || claims["email_verified"] == "true"
+
+	var user User
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var identity UserIdentity
+		if err := tx.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err == nil {
+			return tx.First(&user, identity.UserID).Error
+		}
+
+		if email == "" || !verified {
+			return errors.New("identity provider did not return a verified email")
+		}
+
+		if err := tx.Where("email = ?", email).First(&user).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			// First sign-in: create an account without a usable password
+			if name == "" {
+				name = email
+			}
+			now := time.Now()
+			user = User{Name: name, Email: email, EmailVerifiedAt: &now}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			if err := recordAudit(tx, c, AuditActionCreate, AuditEntityUser, user.ID, nil, user); err != nil {
+				return err
+			}
+		} else if user.EmailVerifiedAt == nil {
+			// Whoever registered the account may not own the address; they must verify it first
+			return errOIDCAccountUnverified
+		}
+
+		identity = UserIdentity{UserID: user.ID, Provider: provider, Subject: subject, Email: email}
+		if err := tx.Create(&identity).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionCreate, AuditEntityUserIdentity, identity.ID, nil, identity)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// OIDCLogin redirects the browser to the identity provider using authorization code + PKCE
+func OIDCLogin(c *gin.Context) {
+	provider, ok := OIDCProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Unknown identity provider")})
+		return
+	}
+
+	discovery, err := provider.Discover()
+	if err != nil {
+		log.Println("OIDC discovery failed:", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": T(c, "Identity provider is unavailable")})
+		return
+	}
+
+	state, err1 := randomToken(16)
+	nonce, err2 := randomToken(16)
+	verifier, err3 := randomToken(32)
+	if err1 != nil || err2 != nil || err3 != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to generate token")})
+		return
+	}
+
+	// Keep the flow's secrets in a signed, short-lived cookie instead of server-side state
+	stateToken, err := signToken(jwt.MapClaims{
+		"purpose":  "oidc",
+		"provider": provider.Name,
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+		"exp":      time.Now().Add(10 * time.Minute).Unix(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to generate token")})
+		return
+	}
+	setOIDCStateCookie(c, stateToken, 600)
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", provider.ClientID)
+	params.Set("redirect_uri", provider.RedirectURL)
+	params.Set("scope", "openid email profile")
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", pkceChallenge(verifier))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	c.Redirect(http.StatusFound, discovery.AuthorizationEndpoint+separator+params.Encode())
+}
+
+// OIDCCallback completes the login, links the identity and issues the same session cookie as Login
+func OIDCCallback(c *gin.Context) {
+	provider, ok := OIDCProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Unknown identity provider")})
+		return
+	}
+
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Identity provider login failed"), "details": providerError})
+		return
+	}
+
+	// The state cookie is single-use
+	cookie, err := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, "", -1)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Login session expired, please try again")})
+		return
+	}
+
+	stateClaims, err := parseToken(cookie)
+	if err != nil || stateClaims["purpose"] != "oidc" || stateClaims["provider"] != provider.Name || stateClaims["state"] != c.Query("state") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Login session expired, please try again")})
+		return
+	}
+	nonce, _ := stateClaims["nonce"].(string)
+	verifier, _ := stateClaims["verifier"].(string)
+
+	discovery, err := provider.Discover()
+	if err != nil {
+		log.Println("OIDC discovery failed:", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": T(c, "Identity provider is unavailable")})
+		return
+	}
+
+	idToken, err := provider.exchangeCode(discovery, c.Query("code"), verifier)
+	if err != nil {
+		log.Println("OIDC code exchange failed:", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Identity provider login failed")})
+		return
+	}
+
+	claims, err := provider.verifyIDToken(discovery, idToken, nonce)
+	if err != nil {
+		log.Println("OIDC ID token rejected:", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Identity provider login failed")})
+		return
+	}
+
+	user, err := linkOIDCIdentity(c, provider.Name, claims)
+	if errors.Is(err, errOIDCAccountUnverified) {
+		c.JSON(http.StatusConflict, gin.H{"error": T(c, "Verify your email before signing in with this provider")})
+		return
+	}
+	if err != nil {
+		log.Println("OIDC account linking failed:", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Identity provider login failed")})
+		return
+	}
+
+	if user.DisabledAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": T(c, "Account disabled")})
+		return
+	}
+
+	// Two-factor users still need their second step; hand the challenge to the frontend
+	if user.TOTPEnabled {
+		challenge, err := generateChallengeToken(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to generate token")})
+			return
+		}
+		c.Redirect(http.StatusFound, appURL()+"/login/2fa?challenge_token="+url.QueryEscape(challenge))
+		return
+	}
+
+	token, err := generateToken(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to generate token")})
+		return
+	}
+
+	setAuthCookie(c, token)
+	c.Redirect(http.StatusFound, appURL()+"/")
+}
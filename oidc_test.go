@@ -0,0 +1,279 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockOIDCProvider is an identity provider serving discovery, JWKS and token endpoints.
+// The test plays the browser: it reads the authorization request and issues codes itself.
+type mockOIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]mockOIDCGrant // Issued authorization codes
+}
+
+// mockOIDCGrant is what the provider remembers about an authorization code
+type mockOIDCGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockOIDCProvider{key: key, grants: make(map[string]mockOIDCGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                m.server.URL,
+			AuthorizationEndpoint: m.server.URL + "/authorize",
+			TokenEndpoint:         m.server.URL + "/token",
+			JWKSURI:               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(JWKS{Keys: []JWK{{
+			Kty: "RSA",
+			Kid: "mock",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		grant, ok := m.grants[r.FormValue("code")]
+		delete(m.grants, r.FormValue("code"))
+		m.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if !ok || pkceChallenge(r.FormValue("code_verifier")) != grant.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, grant.claims)
+		token.Header["kid"] = "mock"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// setupOIDCTest registers the mock as provider "mock" and returns a router with the OIDC routes
+func setupOIDCTest(t *testing.T) (*mockOIDCProvider, *gin.Engine) {
+	setupTestDB(t, &User{}, &UserIdentity{}, &AuditLog{})
+
+	useTestKeys(t)
+	previous := OIDCProviders
+	t.Cleanup(func() { OIDCProviders = previous })
+
+	mock := newMockOIDCProvider(t)
+	OIDCProviders = map[string]*OIDCProvider{"mock": {
+		Name:         "mock",
+		DiscoveryURL: mock.server.URL,
+		ClientID:     "gobudget",
+		RedirectURL:  "http://localhost/auth/oidc/mock/callback",
+	}}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/auth/oidc/:provider/login", OIDCLogin)
+	router.GET("/auth/oidc/:provider/callback", OIDCCallback)
+	return mock, router
+}
+
+// oidcSignIn runs the login flow for the given ID token claims and returns the callback response
+func oidcSignIn(t *testing.T, mock *mockOIDCProvider, router *gin.Engine, claims jwt.MapClaims) *httptest.ResponseRecorder {
+	login := httptest.NewRecorder()
+	router.ServeHTTP(login, httptest.NewRequest(http.MethodGet, "/auth/oidc/mock/login", nil))
+	if login.Code != http.StatusFound {
+		t.Fatalf("login: status %d, body %s", login.Code, login.Body.String())
+	}
+	location, err := url.Parse(login.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := location.Query()
+	if params.Get("code_challenge_method") != "S256" || params.Get("code_challenge") == "" {
+		t.Fatalf("authorization request without PKCE: %s", location)
+	}
+
+	// The provider puts the request's nonce into the ID token it issues for the code
+	issued := jwt.MapClaims{
+		"iss":   mock.server.URL,
+		"aud":   "gobudget",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": params.Get("nonce"),
+	}
+	for name, value := range claims {
+		issued[name] = value
+	}
+	mock.mu.Lock()
+	mock.grants["code-"+params.Get("state")] = mockOIDCGrant{challenge: params.Get("code_challenge"), claims: issued}
+	mock.mu.Unlock()
+
+	callback := httptest.NewRequest(http.MethodGet, "/auth/oidc/mock/callback?code=code-"+params.Get("state")+"&state="+params.Get("state"), nil)
+	for _, cookie := range login.Result().Cookies() {
+		callback.AddCookie(cookie)
+	}
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, callback)
+	return response
+}
+
+// sessionCookie returns the session cookie set by a response, if any
+func sessionCookie(response *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range response.Result().Cookies() {
+		if cookie.Name == "token" && cookie.Value != "" {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestOIDCCallbackCreatesAccountForNewIdentity(t *testing.T) {
+	mock, router := setupOIDCTest(t)
+
+	response := oidcSignIn(t, mock, router, jwt.MapClaims{"sub": "new-subject", "email": "new@example.com", "email_verified": true, "name": "New"})
+	if response.Code != http.StatusFound || sessionCookie(response) == nil {
+		t.Fatalf("status %d, body %s; want a redirect with a session", response.Code, response.Body.String())
+	}
+
+	var user User
+	if err := DB.Where("email = ?", "new@example.com").First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerifiedAt == nil || user.Password != "" {
+		t.Errorf("new account: verified %v, password %q; want verified without a password", user.EmailVerifiedAt, user.Password)
+	}
+	var identities int64
+	DB.Model(&UserIdentity{}).Where("user_id = ? AND provider = ? AND subject = ?", user.ID, "mock", "new-subject").Count(&identities)
+	if identities != 1 {
+		t.Errorf("linked identities = %d, want 1", identities)
+	}
+	var audited int64
+	DB.Model(&AuditLog{}).Where("action = ? AND entity_type IN ?", AuditActionCreate, []string{AuditEntityUser, AuditEntityUserIdentity}).Count(&audited)
+	if audited != 2 {
+		t.Errorf("audit entries = %d, want one for the user and one for the identity", audited)
+	}
+
+	// Signing in again reuses the identity
+	response = oidcSignIn(t, mock, router, jwt.MapClaims{"sub": "new-subject"})
+	if response.Code != http.StatusFound || sessionCookie(response) == nil {
+		t.Fatalf("second sign-in: status %d, body %s", response.Code, response.Body.String())
+	}
+}
+
+func TestOIDCCallbackLinksOnlyVerifiedAccounts(t *testing.T) {
+	mock, router := setupOIDCTest(t)
+
+	now := time.Now()
+	verified := User{Name: "Verified", Email: "verified@example.com", Password: "hash", EmailVerifiedAt: &now}
+	unverified := User{Name: "Unverified", Email: "unverified@example.com", Password: "hash"}
+	if err := DB.Create(&verified).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Create(&unverified).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	response := oidcSignIn(t, mock, router, jwt.MapClaims{"sub": "subject-1", "email": "verified@example.com", "email_verified": "true"})
+	if response.Code != http.StatusFound || sessionCookie(response) == nil {
+		t.Fatalf("verified account: status %d, body %s; want a redirect with a session", response.Code, response.Body.String())
+	}
+	var identity UserIdentity
+	if err := DB.Where("subject = ?", "subject-1").First(&identity).Error; err != nil || identity.UserID != verified.ID {
+		t.Fatalf("verified account: identity %+v, err %v; want it linked to user %d", identity, err, verified.ID)
+	}
+
+	response = oidcSignIn(t, mock, router, jwt.MapClaims{"sub": "subject-2", "email": "unverified@example.com", "email_verified": true})
+	if response.Code != http.StatusConflict || sessionCookie(response) != nil {
+		t.Fatalf("unverified account: status %d; want 409 without a session", response.Code)
+	}
+	var identities int64
+	DB.Model(&UserIdentity{}).Where("user_id = ?", unverified.ID).Count(&identities)
+	if identities != 0 {
+		t.Errorf("unverified account got %d linked identities, want none", identities)
+	}
+
+	// An email the provider itself has not verified never links
+	response = oidcSignIn(t, mock, router, jwt.MapClaims{"sub": "subject-3", "email": "verified@example.com", "email_verified": false})
+	if response.Code != http.StatusUnauthorized {
+		t.Fatalf("unverified claim: status %d, want 401", response.Code)
+	}
+}
+
+func TestOIDCCallbackHandsTwoFactorUsersAChallenge(t *testing.T) {
+	mock, router := setupOIDCTest(t)
+
+	now := time.Now()
+	user := User{Name: "Two Factor", Email: "2fa@example.com", EmailVerifiedAt: &now, TOTPEnabled: true, TOTPSecret: rfc6238Secret}
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	response := oidcSignIn(t, mock, router, jwt.MapClaims{"sub": "subject-2fa", "email": "2fa@example.com", "email_verified": true})
+	if response.Code != http.StatusFound || sessionCookie(response) != nil {
+		t.Fatalf("status %d; want a redirect without a session", response.Code)
+	}
+	location, err := url.Parse(response.Header().Get("Location"))
+	if err != nil || !strings.HasSuffix(location.Path, "/login/2fa") {
+		t.Fatalf("redirected to %q, want the two-factor page", response.Header().Get("Location"))
+	}
+	claims, err := parseToken(location.Query().Get("challenge_token"))
+	if err != nil || claims["purpose"] != "2fa" || claims["user_id"] != float64(user.ID) {
+		t.Fatalf("challenge token claims %v, err %v", claims, err)
+	}
+}
+
+func TestOIDCCallbackRejectsWrongPKCEVerifier(t *testing.T) {
+	mock, router := setupOIDCTest(t)
+
+	login := httptest.NewRecorder()
+	router.ServeHTTP(login, httptest.NewRequest(http.MethodGet, "/auth/oidc/mock/login", nil))
+	location, err := url.Parse(login.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := location.Query().Get("state")
+
+	// The code was issued for a different challenge than the one the login request sent
+	mock.mu.Lock()
+	mock.grants["stolen"] = mockOIDCGrant{challenge: pkceChallenge("someone-else"), claims: jwt.MapClaims{"sub": "x"}}
+	mock.mu.Unlock()
+	callback := httptest.NewRequest(http.MethodGet, "/auth/oidc/mock/callback?code=stolen&state="+state, nil)
+	for _, cookie := range login.Result().Cookies() {
+		callback.AddCookie(cookie)
+	}
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, callback)
+	if response.Code != http.StatusUnauthorized || sessionCookie(response) != nil {
+		t.Fatalf("status %d; want 401 without a session", response.Code)
+	}
+}
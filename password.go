@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Purposes of the single-use tokens sent by email
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+)
+
+// Lifetimes of the emailed tokens
+const (
+	verifyEmailTokenTTL   = 48 * time.Hour
+	resetPasswordTokenTTL = time.Hour
+)
+
+// errInvalidUserToken is returned when a token is unknown, expired or already used
+var errInvalidUserToken = errors.New("invalid or expired token")
+
+// appURL returns the frontend base URL used in emailed links
+func appURL() string {
+	if url := os.Getenv("APP_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:3000"
+}
+
+// requireEmailVerification reports whether Login should refuse unverified users
+func requireEmailVerification() bool {
+	return os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true"
+}
+
+// randomToken returns a URL-safe random token with n bytes of entropy
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken returns the SHA-256 hex digest under which a token is stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueUserToken creates a single-use token for the user and returns its plaintext value
+func issueUserToken(userID uint, purpose string, ttl time.Duration) (string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	token := UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := DB.Create(&token).Error; err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// consumeUserToken marks a token as used and returns it, failing if it was already used or has expired
+func consumeUserToken(tx *gorm.DB, raw, purpose string) (*UserToken, error) {
+	var token UserToken
+	if err := tx.Where("token_hash = ? AND purpose = ?", hashToken(raw), purpose).First(&token).Error; err != nil {
+		return nil, errInvalidUserToken
+	}
+
+	// The conditional update makes the token single-use even under concurrent requests
+	now := time.Now()
+	result := tx.Model(&UserToken{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", token.ID, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return nil, errInvalidUserToken
+	}
+	return &token, nil
+}
+
+// sendVerificationEmail emails the user a link to confirm their address
+func sendVerificationEmail(user *User) error {
+	token, err := issueUserToken(user.ID, TokenPurposeVerifyEmail, verifyEmailTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s/verify-email?token=%s\n\nThe link expires in %s.\n",
+		user.Name, appURL(), token, verifyEmailTokenTTL)
+	return AppMailer.Send(user.Email, "Confirm your GoBudget email address", body)
+}
+
+// ForgotPassword emails a password reset link if the address belongs to an account
+func ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Always answer the same way so the endpoint does not reveal which emails are registered
+	response := gin.H{"message": T(c, "If the email is registered, a reset link has been sent")}
+
+	var user User
+	if err := DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := issueUserToken(user.ID, TokenPurposeResetPassword, resetPasswordTokenTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to generate token")})
+		return
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nSomeone asked to reset your GoBudget password. Open the link below to choose a new one:\n\n%s/reset-password?token=%s\n\nThe link expires in %s. If you did not ask for this, you can ignore this email.\n",
+		user.Name, appURL(), token, resetPasswordTokenTTL)
+	if err := AppMailer.Send(user.Email, "Reset your GoBudget password", body); err != nil {
+		log.Println("Failed to send password reset email:", err)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword
+func ResetPassword(c *gin.Context) {
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,min=8"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to hash password")})
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, input.Token, TokenPurposeResetPassword)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&User{}).Where("id = ?", token.UserID).Update("password", string(hashedPassword)).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(tx, c, AuditActionChangePassword, AuditEntityUser, token.UserID, nil, nil); err != nil {
+			return err
+		}
+
+		// Any other outstanding reset links stop working once the password has changed
+		return tx.Model(&UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", token.UserID, TokenPurposeResetPassword).
+			Update("used_at", time.Now()).Error
+	})
+	if errors.Is(err, errInvalidUserToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Invalid or expired token")})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to reset password")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Password has been reset")})
+}
+
+// VerifyEmail confirms a user's email address using a token from the verification email
+func VerifyEmail(c *gin.Context) {
+	var input struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, input.Token, TokenPurposeVerifyEmail)
+		if err != nil {
+			return err
+		}
+
+		var user User
+		if err := tx.First(&user, token.UserID).Error; err != nil {
+			return err
+		}
+		before := user
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		if err := tx.Model(&user).Update("email_verified_at", now).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionUpdate, AuditEntityUser, user.ID, before, user)
+	})
+	if errors.Is(err, errInvalidUserToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Invalid or expired token")})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to verify email")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Email verified")})
+}
+
+// ResendVerificationEmail sends a fresh verification link to the authenticated user
+func ResendVerificationEmail(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "User not found")})
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusOK, gin.H{"message": T(c, "Email already verified")})
+		return
+	}
+
+	if err := sendVerificationEmail(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to send verification email")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Verification email sent")})
+}
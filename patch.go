@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Optional is a request field that tells an absent field apart from an explicit null and a value,
+// which PATCH needs: absent leaves the field alone, null clears it and a value sets it.
+type Optional[T any] struct {
+	Set   bool // The field was present in the request body
+	Null  bool // The field was explicitly null
+	Value T
+}
+
+// UnmarshalJSON records that the field was present; encoding/json also calls it for null
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+// errMonthRequired is returned when a budget update has neither a month nor a start date
+var errMonthRequired = errors.New("month or start_date is required")
+
+// validateTransaction applies the rules every stored transaction must satisfy, whether it was
+// created, replaced or patched
+func validateTransaction(t *Transaction) error {
+	if t.Type != "Income" && t.Type != "Expense" {
+		return fmt.Errorf("invalid type %q, expected Income or Expense", t.Type)
+	}
+	if t.Amount <= 0 {
+		return errors.New("amount must be greater than zero")
+	}
+	if strings.TrimSpace(t.Currency) == "" {
+		return errors.New("currency is required")
+	}
+	if t.ExchangeRate <= 0 {
+		return errors.New("exchange_rate must be greater than zero")
+	}
+	return nil
+}
+
+// validateBudget applies the rules every stored budget must satisfy
+func validateBudget(b *Budget, loc *time.Location) error {
+	// Percentage budgets start at zero until income arrives
+	if b.Amount <= 0 && b.Percent == nil {
+		return errors.New("amount must be greater than zero")
+	}
+	if strings.TrimSpace(b.Currency) == "" {
+		return errors.New("currency is required")
+	}
+	if b.ExchangeRate <= 0 {
+		return errors.New("exchange_rate must be greater than zero")
+	}
+	return normalizeBudgetPeriod(b, loc)
+}
+
+// transactionInput is the body for creating or fully replacing a transaction
+type transactionInput struct {
+	Type         string  `json:"type" binding:"required"`
+	Amount       float64 `json:"amount" binding:"required"`
+	Currency     string  `json:"currency" binding:"required"`
+	ExchangeRate float64 `json:"exchange_rate" binding:"required"`
+	Note         string  `json:"note"`
+	CategoryID   uint    `json:"category_id"` // Zero leaves the transaction uncategorized
+	OccurredAt   string  `json:"occurred_at"` // Keeps the transaction's current date when omitted
+	PayeeID      uint    `json:"payee_id"`    // Zero leaves the payee to be resolved from Payee
+	Payee        string  `json:"payee"`       // Raw bank description, normalized to a payee through aliases
+}
+
+// apply overwrites every field of t with the input and validates the result
+func (input transactionInput) apply(t *Transaction, loc *time.Location) error {
+	if input.OccurredAt != "" {
+		parsed, err := parseOccurredAt(input.OccurredAt, loc)
+		if err != nil {
+			return err
+		}
+		t.OccurredAt = parsed
+	}
+
+	t.Type = input.Type
+	t.Amount = input.Amount
+	t.Currency = input.Currency
+	t.ExchangeRate = input.ExchangeRate
+	t.Note = input.Note
+	t.CategoryID = nil
+	if input.CategoryID != 0 {
+		categoryID := input.CategoryID
+		t.CategoryID = &categoryID
+	}
+	t.PayeeID = nil
+	if input.PayeeID != 0 {
+		payeeID := input.PayeeID
+		t.PayeeID = &payeeID
+	}
+	return validateTransaction(t)
+}
+
+// transactionPatch is the body of a partial transaction update
+type transactionPatch struct {
+	Type         Optional[string]  `json:"type"`
+	Amount       Optional[float64] `json:"amount"`
+	Currency     Optional[string]  `json:"currency"`
+	ExchangeRate Optional[float64] `json:"exchange_rate"`
+	Note         Optional[string]  `json:"note"`        // Null clears the note
+	CategoryID   Optional[uint]    `json:"category_id"` // Null removes the category
+	OccurredAt   Optional[string]  `json:"occurred_at"`
+	PayeeID      Optional[uint]    `json:"payee_id"` // Null removes the payee
+	Payee        Optional[string]  `json:"payee"`    // Raw description to resolve when payee_id is absent
+}
+
+// apply changes the fields present in the patch and validates the result
+func (patch transactionPatch) apply(t *Transaction, loc *time.Location) error {
+	for _, field := range []struct {
+		name string
+		null bool
+	}{
+		{"type", patch.Type.Null},
+		{"amount", patch.Amount.Null},
+		{"currency", patch.Currency.Null},
+		{"exchange_rate", patch.ExchangeRate.Null},
+		{"occurred_at", patch.OccurredAt.Null},
+	} {
+		if field.null {
+			return fmt.Errorf("%s cannot be null", field.name)
+		}
+	}
+
+	if patch.OccurredAt.Set {
+		parsed, err := parseOccurredAt(patch.OccurredAt.Value, loc)
+		if err != nil {
+			return err
+		}
+		t.OccurredAt = parsed
+	}
+	if patch.Type.Set {
+		t.Type = patch.Type.Value
+	}
+	if patch.Amount.Set {
+		t.Amount = patch.Amount.Value
+	}
+	if patch.Currency.Set {
+		t.Currency = patch.Currency.Value
+	}
+	if patch.ExchangeRate.Set {
+		t.ExchangeRate = patch.ExchangeRate.Value
+	}
+	if patch.Note.Set {
+		t.Note = patch.Note.Value // Null leaves Value empty
+	}
+	if patch.CategoryID.Set {
+		t.CategoryID = nil
+		if !patch.CategoryID.Null && patch.CategoryID.Value != 0 {
+			categoryID := patch.CategoryID.Value
+			t.CategoryID = &categoryID
+		}
+	}
+	if patch.PayeeID.Set || patch.Payee.Set {
+		t.PayeeID = nil
+		if patch.PayeeID.Set && !patch.PayeeID.Null && patch.PayeeID.Value != 0 {
+			payeeID := patch.PayeeID.Value
+			t.PayeeID = &payeeID
+		}
+	}
+	return validateTransaction(t)
+}
+
+// budgetInput is the body for fully replacing a budget; CreateBudget extends it with a category
+type budgetInput struct {
+	Amount       float64 `json:"amount" binding:"required"`
+	Currency     string  `json:"currency" binding:"required"`
+	ExchangeRate float64 `json:"exchange_rate" binding:"required"`
+	Month        string  `json:"month"`      // For monthly budgets
+	Period       string  `json:"period"`     // Defaults to monthly
+	StartDate    string  `json:"start_date"` // For weekly, quarterly, yearly and custom budgets
+	EndDate      string  `json:"end_date"`   // For custom budgets
+}
+
+// apply overwrites every field of b with the input and validates the result
+func (input budgetInput) apply(b *Budget, loc *time.Location) error {
+	b.Amount = input.Amount
+	b.Percent = nil // A fixed amount replaces a percentage of income
+	b.Currency = input.Currency
+	b.ExchangeRate = input.ExchangeRate
+	b.Month = input.Month
+	b.PeriodType = input.Period
+	b.StartDate = input.StartDate
+	b.EndDate = input.EndDate
+	return validateBudget(b, loc)
+}
+
+// budgetPatch is the body of a partial budget update
+type budgetPatch struct {
+	Amount       Optional[float64] `json:"amount"`
+	Currency     Optional[string]  `json:"currency"`
+	ExchangeRate Optional[float64] `json:"exchange_rate"`
+	Month        Optional[string]  `json:"month"`
+	Period       Optional[string]  `json:"period"`
+	StartDate    Optional[string]  `json:"start_date"`
+	EndDate      Optional[string]  `json:"end_date"`
+}
+
+// apply changes the fields present in the patch and validates the result
+func (patch budgetPatch) apply(b *Budget, loc *time.Location) error {
+	for _, field := range []struct {
+		name string
+		null bool
+	}{
+		{"amount", patch.Amount.Null},
+		{"currency", patch.Currency.Null},
+		{"exchange_rate", patch.ExchangeRate.Null},
+		{"month", patch.Month.Null},
+		{"period", patch.Period.Null},
+		{"start_date", patch.StartDate.Null},
+		{"end_date", patch.EndDate.Null},
+	} {
+		if field.null {
+			return fmt.Errorf("%s cannot be null", field.name)
+		}
+	}
+
+	if patch.Amount.Set {
+		b.Amount = patch.Amount.Value
+		b.Percent = nil // A fixed amount replaces a percentage of income
+	}
+	// A percentage-of-income budget is computed in IDR; only a fixed amount can have another currency
+	if b.Percent != nil && (patch.Currency.Set || patch.ExchangeRate.Set) {
+		return errors.New("currency and exchange_rate cannot be changed on a percentage-of-income budget without setting an amount")
+	}
+	if patch.Currency.Set {
+		b.Currency = patch.Currency.Value
+	}
+	if patch.ExchangeRate.Set {
+		b.ExchangeRate = patch.ExchangeRate.Value
+	}
+	if patch.Period.Set {
+		b.PeriodType = patch.Period.Value
+	}
+	if patch.StartDate.Set {
+		b.StartDate = patch.StartDate.Value
+		b.Month = "" // Derived from the new start date
+	}
+	if patch.EndDate.Set {
+		b.EndDate = patch.EndDate.Value
+	}
+	if patch.Month.Set {
+		b.Month = patch.Month.Value
+	}
+	return validateBudget(b, loc)
+}
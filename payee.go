@@ -0,0 +1,446 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Audited payee entity types
+const (
+	AuditEntityPayee      = "payee"
+	AuditEntityPayeeAlias = "payee_alias"
+)
+
+// maxPayeeNameLength matches the size of Payee.Name
+const maxPayeeNameLength = 100
+
+// errPayeeNotFound is returned when a transaction names a payee the user does not have
+var errPayeeNotFound = errors.New("payee not found")
+
+// payeeNoiseWords are bank and card-network words that prefix raw descriptions without saying who
+// was paid, e.g. "POS DEBIT 1234 INDOMARET JKT"
+var payeeNoiseWords = map[string]bool{
+	"pos": true, "debit": true, "kartu": true, "card": true, "trf": true, "transfer": true, "qris": true,
+	"edc": true, "purchase": true, "payment": true, "pembayaran": true, "pembelian": true, "byr": true,
+}
+
+// normalizePayeeDescription reduces a raw bank description to the lowercase words that identify
+// the payee: digits, punctuation, single letters and leading noise words are dropped
+func normalizePayeeDescription(raw string) string {
+	words := strings.FieldsFunc(strings.ToLower(raw), func(r rune) bool { return !unicode.IsLetter(r) })
+	var kept []string
+	for _, word := range words {
+		if len(word) < 2 || (len(kept) == 0 && payeeNoiseWords[word]) {
+			continue
+		}
+		kept = append(kept, word)
+	}
+	return strings.Join(kept, " ")
+}
+
+// payeeNameFromDescription turns a normalized description into a display name for a new payee
+func payeeNameFromDescription(normalized string) string {
+	words := strings.Fields(normalized)
+	for i, word := range words {
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	name := []rune(strings.Join(words, " "))
+	if len(name) > maxPayeeNameLength {
+		name = name[:maxPayeeNameLength]
+	}
+	return strings.TrimSpace(string(name))
+}
+
+// matchPayeeAlias finds the alias for a normalized description: an exact match wins, otherwise the
+// longest alias that appears in the description as whole words
+func matchPayeeAlias(aliases []PayeeAlias, normalized string) *PayeeAlias {
+	var best *PayeeAlias
+	padded := " " + normalized + " "
+	for i := range aliases {
+		alias := &aliases[i]
+		if alias.Pattern == normalized {
+			return alias
+		}
+		if strings.Contains(padded, " "+alias.Pattern+" ") && (best == nil || len(alias.Pattern) > len(best.Pattern)) {
+			best = alias
+		}
+	}
+	return best
+}
+
+// resolvePayee returns the user's payee for a raw description, creating a payee and alias the
+// first time an unrecognized description is seen. It returns nil when nothing identifying is left.
+func resolvePayee(tx *gorm.DB, userID uint, raw string) (*Payee, error) {
+	normalized := normalizePayeeDescription(raw)
+	if normalized == "" {
+		return nil, nil
+	}
+
+	var aliases []PayeeAlias
+	if err := tx.Where("user_id = ?", userID).Find(&aliases).Error; err != nil {
+		return nil, err
+	}
+	if alias := matchPayeeAlias(aliases, normalized); alias != nil {
+		var payee Payee
+		err := tx.First(&payee, alias.PayeeID).Error
+		return &payee, err
+	}
+
+	payee := Payee{UserID: userID, Name: payeeNameFromDescription(normalized)}
+	if err := tx.Where("user_id = ? AND name = ?", userID, payee.Name).FirstOrCreate(&payee).Error; err != nil {
+		return nil, err
+	}
+	alias := PayeeAlias{UserID: userID, PayeeID: payee.ID, Pattern: normalized}
+	if err := tx.Create(&alias).Error; err != nil {
+		return nil, err
+	}
+	return &payee, nil
+}
+
+// setTransactionPayee links a transaction to its payee: an explicit payee_id must belong to the
+// user, and otherwise a raw description is resolved through the user's aliases
+func setTransactionPayee(tx *gorm.DB, t *Transaction, raw string) error {
+	if t.PayeeID != nil {
+		var count int64
+		if err := tx.Model(&Payee{}).Where("id = ? AND user_id = ?", *t.PayeeID, t.UserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return errPayeeNotFound
+		}
+		return nil
+	}
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+
+	payee, err := resolvePayee(tx, t.UserID, raw)
+	if err != nil || payee == nil {
+		return err
+	}
+	t.PayeeID = &payee.ID
+	return nil
+}
+
+// GetPayees lists the user's payees with their aliases
+func GetPayees(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var payees []Payee
+	if err := DB.Preload("Aliases").Where("user_id = ?", userID).Order("name ASC").Find(&payees).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch payees")})
+		return
+	}
+
+	c.JSON(http.StatusOK, payees)
+}
+
+// CreatePayee adds a payee, optionally with aliases for the raw descriptions that mean it
+func CreatePayee(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var input struct {
+		Name    string   `json:"name" binding:"required"`
+		Aliases []string `json:"aliases"` // Raw descriptions; stored normalized
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payee := Payee{UserID: userID.(uint), Name: strings.TrimSpace(input.Name)}
+	if payee.Name == "" || len(payee.Name) > maxPayeeNameLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Invalid payee name")})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&payee).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(tx, c, AuditActionCreate, AuditEntityPayee, payee.ID, nil, payee); err != nil {
+			return err
+		}
+		for _, raw := range input.Aliases {
+			alias, err := addPayeeAlias(tx, c, &payee, raw)
+			if err != nil {
+				return err
+			}
+			payee.Aliases = append(payee.Aliases, *alias)
+		}
+		return nil
+	})
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": T(c, "Payee or alias already exists")})
+		return
+	}
+	if errors.Is(err, errInvalidAlias) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Invalid payee alias")})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to create payee")})
+		return
+	}
+
+	c.JSON(http.StatusCreated, payee)
+}
+
+// UpdatePayee renames a payee
+func UpdatePayee(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var payee Payee
+	if err := DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&payee).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Payee not found")})
+		return
+	}
+
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(input.Name)
+	if name == "" || len(name) > maxPayeeNameLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Invalid payee name")})
+		return
+	}
+
+	before := payee
+	payee.Name = name
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&payee).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionUpdate, AuditEntityPayee, payee.ID, before, payee)
+	})
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": T(c, "Payee or alias already exists")})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update payee")})
+		return
+	}
+
+	c.JSON(http.StatusOK, payee)
+}
+
+// DeletePayee removes a payee and its aliases; its transactions are kept without a payee
+func DeletePayee(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var payee Payee
+	if err := DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&payee).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Payee not found")})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// Deleted transactions reference the payee too
+		if err := tx.Unscoped().Model(&Transaction{}).Where("payee_id = ?", payee.ID).Update("payee_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("payee_id = ?", payee.ID).Delete(&PayeeAlias{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&payee).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionDelete, AuditEntityPayee, payee.ID, payee, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to delete payee")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Payee deleted")})
+}
+
+// errInvalidAlias is returned for an alias with nothing left after normalization
+var errInvalidAlias = errors.New("invalid payee alias")
+
+// addPayeeAlias stores a raw description as an alias of the payee
+func addPayeeAlias(tx *gorm.DB, c *gin.Context, payee *Payee, raw string) (*PayeeAlias, error) {
+	alias := PayeeAlias{UserID: payee.UserID, PayeeID: payee.ID, Pattern: normalizePayeeDescription(raw)}
+	if alias.Pattern == "" || len(alias.Pattern) > 255 {
+		return nil, errInvalidAlias
+	}
+	if err := tx.Create(&alias).Error; err != nil {
+		return nil, err
+	}
+	return &alias, recordAudit(tx, c, AuditActionCreate, AuditEntityPayeeAlias, alias.ID, nil, alias)
+}
+
+// CreatePayeeAlias teaches a payee another raw description. Only transactions created from now on
+// resolve through the new alias; existing ones keep their payee.
+func CreatePayeeAlias(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var payee Payee
+	if err := DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&payee).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Payee not found")})
+		return
+	}
+
+	var input struct {
+		Description string `json:"description" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var alias *PayeeAlias
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		alias, err = addPayeeAlias(tx, c, &payee, input.Description)
+		return err
+	})
+	if errors.Is(err, errInvalidAlias) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Invalid payee alias")})
+		return
+	}
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": T(c, "Payee or alias already exists")})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update payee")})
+		return
+	}
+
+	c.JSON(http.StatusCreated, alias)
+}
+
+// DeletePayeeAlias removes one alias from a payee
+func DeletePayeeAlias(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var alias PayeeAlias
+	if err := DB.Where("id = ? AND payee_id = ? AND user_id = ?", c.Param("alias_id"), c.Param("id"), userID).First(&alias).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Payee alias not found")})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&alias).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionDelete, AuditEntityPayeeAlias, alias.ID, alias, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update payee")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Payee alias deleted")})
+}
+
+// reportPeriod reads the inclusive from/to dates of a report, defaulting to the current month in loc
+func reportPeriod(c *gin.Context, loc *time.Location) (time.Time, time.Time, error) {
+	now := time.Now().In(loc)
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 1, 0)
+
+	if from := c.Query("from"); from != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", from, loc)
+		if err != nil {
+			return start, end, errors.New("invalid from date, expected YYYY-MM-DD")
+		}
+		start = parsed
+	}
+	if to := c.Query("to"); to != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", to, loc)
+		if err != nil {
+			return start, end, errors.New("invalid to date, expected YYYY-MM-DD")
+		}
+		end = parsed.AddDate(0, 0, 1)
+	}
+	if !end.After(start) {
+		return start, end, errors.New("to date must not be before from date")
+	}
+	return start, end, nil
+}
+
+// PayeeTotal is one row of the payee report, with amounts in IDR
+type PayeeTotal struct {
+	PayeeID      uint    `json:"payee_id"`
+	Name         string  `json:"name"`
+	TotalExpense float64 `json:"total_expense"`
+	TotalIncome  float64 `json:"total_income"`
+	Transactions int     `json:"transactions"`
+}
+
+// GetPayeeReport totals income and expenses per payee over a period (?from=&to=, YYYY-MM-DD),
+// highest spending first. The period defaults to the current month.
+func GetPayeeReport(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	start, end, err := reportPeriod(c, userLocation(userID.(uint)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var totals []PayeeTotal
+	err = DB.Model(&Transaction{}).
+		Select("payee.id AS payee_id, payee.name AS name, "+
+			"COALESCE(SUM(CASE WHEN transaction.type = 'Expense' THEN transaction.amount * transaction.exchange_rate ELSE 0 END), 0) AS total_expense, "+
+			"COALESCE(SUM(CASE WHEN transaction.type = 'Income' THEN transaction.amount * transaction.exchange_rate ELSE 0 END), 0) AS total_income, "+
+			"COUNT(*) AS transactions").
+		Joins("JOIN payee ON payee.id = transaction.payee_id").
+		Where("transaction.user_id = ? AND transaction.deleted_at IS NULL", userID).
+		Where("transaction.occurred_at >= ? AND transaction.occurred_at < ?", start, end).
+		Group("payee.id, payee.name").
+		Order("total_expense DESC, name ASC").
+		Scan(&totals).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch payee report")})
+		return
+	}
+	if totals == nil {
+		totals = []PayeeTotal{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"from": start.Format("2006-01-02"), "to": end.AddDate(0, 0, -1).Format("2006-01-02"), "payees": totals})
+}
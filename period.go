@@ -0,0 +1,228 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Budget period types
+const (
+	BudgetPeriodWeekly    = "weekly"
+	BudgetPeriodMonthly   = "monthly"
+	BudgetPeriodQuarterly = "quarterly"
+	BudgetPeriodYearly    = "yearly"
+	BudgetPeriodCustom    = "custom"
+)
+
+// Pace statuses reported by GET /budgets/pace
+const (
+	PaceOnPace     = "on_pace"
+	PaceAhead      = "ahead_of_pace" // Spending faster than the period allows
+	PaceOverBudget = "over_budget"
+)
+
+// dateLayout is the format of Budget.StartDate and Budget.EndDate
+const dateLayout = "2006-01-02"
+
+// normalizeBudgetPeriod fills in a budget's dates from its period type. Monthly budgets are
+// described by Month, weekly, quarterly and yearly ones by StartDate (the end follows from the
+// length), and custom ones by both dates. Month is always set to the month the budget starts in.
+func normalizeBudgetPeriod(b *Budget, loc *time.Location) error {
+	if b.PeriodType == "" {
+		b.PeriodType = BudgetPeriodMonthly
+	}
+
+	var start, end time.Time
+	var err error
+	switch b.PeriodType {
+	case BudgetPeriodMonthly:
+		if b.Month == "" && len(b.StartDate) >= 7 {
+			b.Month = b.StartDate[:7]
+		}
+		if start, end, err = monthRange(b.Month, loc); err != nil {
+			return err
+		}
+		end = end.AddDate(0, 0, -1)
+	case BudgetPeriodWeekly, BudgetPeriodQuarterly, BudgetPeriodYearly:
+		if start, err = time.ParseInLocation(dateLayout, b.StartDate, loc); err != nil {
+			return fmt.Errorf("invalid start_date %q, expected YYYY-MM-DD", b.StartDate)
+		}
+		end = periodEnd(b.PeriodType, start)
+	case BudgetPeriodCustom:
+		if start, err = time.ParseInLocation(dateLayout, b.StartDate, loc); err != nil {
+			return fmt.Errorf("invalid start_date %q, expected YYYY-MM-DD", b.StartDate)
+		}
+		if end, err = time.ParseInLocation(dateLayout, b.EndDate, loc); err != nil {
+			return fmt.Errorf("invalid end_date %q, expected YYYY-MM-DD", b.EndDate)
+		}
+		if end.Before(start) {
+			return errors.New("end_date must not be before start_date")
+		}
+	default:
+		return fmt.Errorf("invalid period %q, expected weekly, monthly, quarterly, yearly or custom", b.PeriodType)
+	}
+
+	b.StartDate = start.Format(dateLayout)
+	b.EndDate = end.Format(dateLayout)
+	b.Month = start.Format("2006-01")
+	return nil
+}
+
+// periodEnd returns the last day of a fixed-length period starting on start
+func periodEnd(period string, start time.Time) time.Time {
+	switch period {
+	case BudgetPeriodWeekly:
+		return start.AddDate(0, 0, 6)
+	case BudgetPeriodQuarterly:
+		return start.AddDate(0, 3, -1)
+	case BudgetPeriodYearly:
+		return start.AddDate(1, 0, -1)
+	}
+	return start.AddDate(0, 1, -1)
+}
+
+// currentPeriodStart returns the first day of the calendar period of the given type that contains
+// today: the week starting Monday, the quarter, or the year
+func currentPeriodStart(period string, today time.Time) time.Time {
+	day := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
+	switch period {
+	case BudgetPeriodWeekly:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case BudgetPeriodQuarterly:
+		return time.Date(day.Year(), day.Month()-(day.Month()-1)%3, 1, 0, 0, 0, 0, day.Location())
+	case BudgetPeriodYearly:
+		return time.Date(day.Year(), 1, 1, 0, 0, 0, 0, day.Location())
+	}
+	return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+}
+
+// defaultPeriod fills in the period a new budget covers when the request leaves it out:
+// the current month, or the current week, quarter or year. Custom periods have no default.
+func (input *budgetInput) defaultPeriod(today time.Time) {
+	switch input.Period {
+	case "", BudgetPeriodMonthly:
+		if input.Month == "" && input.StartDate == "" {
+			input.Month = today.Format("2006-01")
+		}
+	case BudgetPeriodWeekly, BudgetPeriodQuarterly, BudgetPeriodYearly:
+		if input.StartDate == "" {
+			input.StartDate = currentPeriodStart(input.Period, today).Format(dateLayout)
+		}
+	}
+}
+
+// budgetWindow returns the [start, end) window a budget covers, evaluated in loc
+func budgetWindow(b *Budget, loc *time.Location) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation(dateLayout, b.StartDate, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start_date %q", b.StartDate)
+	}
+	end, err := time.ParseInLocation(dateLayout, b.EndDate, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end_date %q", b.EndDate)
+	}
+	return start, end.AddDate(0, 0, 1), nil
+}
+
+// categorySpent sums a user's expenses (in IDR) in a category within [start, end)
+func categorySpent(userID, categoryID uint, start, end time.Time) (float64, error) {
+	var totalSpent sql.NullFloat64
+	err := DB.Model(&Transaction{}).
+		Where("user_id = ? AND category_id = ? AND type = ? AND deleted_at IS NULL", userID, categoryID, "Expense").
+		Where("occurred_at >= ? AND occurred_at < ?", start, end).
+		Select("COALESCE(SUM(amount * exchange_rate), 0)").
+		Scan(&totalSpent).Error
+	return totalSpent.Float64, err
+}
+
+// BudgetPace compares what a budget has spent so far with what it was expected to spend by now,
+// assuming spending is spread evenly over the period. Amounts are in IDR.
+type BudgetPace struct {
+	Budget         Budget  `json:"budget"`
+	Limit          float64 `json:"limit"` // The budget amount converted to IDR
+	ElapsedDays    int     `json:"elapsed_days"`
+	TotalDays      int     `json:"total_days"`
+	ExpectedToDate float64 `json:"expected_to_date"`
+	Projected      float64 `json:"projected"` // Spending by the end of the period at the current rate
+	Status         string  `json:"status"`    // "on_pace", "ahead_of_pace" or "over_budget"
+}
+
+// GetBudgetPace reports the pace of every budget whose period includes ?date=YYYY-MM-DD
+// (default today in the user's timezone), counting spending up to and including that day
+func GetBudgetPace(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	loc := userLocation(userID.(uint))
+	date := time.Now().In(loc).Format(dateLayout)
+	if value := c.Query("date"); value != "" {
+		date = value
+	}
+	day, err := time.ParseInLocation(dateLayout, date, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Invalid date, expected YYYY-MM-DD")})
+		return
+	}
+
+	var budgets []Budget
+	if err := DB.Preload("Category").
+		Where("user_id = ? AND start_date <= ? AND end_date >= ?", userID, date, date).
+		Order("end_date ASC, id ASC").
+		Find(&budgets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch budgets")})
+		return
+	}
+
+	paces := make([]BudgetPace, 0, len(budgets))
+	for _, budget := range budgets {
+		start, end, err := budgetWindow(&budget, loc)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch budgets")})
+			return
+		}
+		spent, err := categorySpent(budget.UserID, budget.CategoryID, start, day.AddDate(0, 0, 1))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch total spent data")})
+			return
+		}
+		budget.Spent = spent
+
+		// Days are counted on the calendar so that DST changes do not shift them
+		pace := BudgetPace{
+			Budget:      budget,
+			Limit:       budget.Amount * budget.ExchangeRate,
+			ElapsedDays: calendarDays(start, day) + 1,
+			TotalDays:   calendarDays(start, end),
+		}
+		share := float64(pace.ElapsedDays) / float64(pace.TotalDays)
+		pace.ExpectedToDate = pace.Limit * share
+		pace.Projected = spent / share
+		switch {
+		case spent > pace.Limit:
+			pace.Status = PaceOverBudget
+		case spent > pace.ExpectedToDate:
+			pace.Status = PaceAhead
+		default:
+			pace.Status = PaceOnPace
+		}
+		paces = append(paces, pace)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"date": date, "budgets": paces})
+}
+
+// calendarDays counts the days from one midnight to another
+func calendarDays(from, to time.Time) int {
+	utcFrom := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	utcTo := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(math.Round(utcTo.Sub(utcFrom).Hours() / 24))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeBudgetPeriodWindows(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		budget    Budget
+		wantStart string
+		wantEnd   string
+		wantMonth string
+		wantDays  int
+	}{
+		{"weekly", Budget{PeriodType: BudgetPeriodWeekly, StartDate: "2024-12-30"}, "2024-12-30", "2025-01-05", "2024-12", 7},
+		{"quarterly", Budget{PeriodType: BudgetPeriodQuarterly, StartDate: "2024-01-01"}, "2024-01-01", "2024-03-31", "2024-01", 91},
+		{"quarterly mid-month", Budget{PeriodType: BudgetPeriodQuarterly, StartDate: "2024-11-15"}, "2024-11-15", "2025-02-14", "2024-11", 92},
+		{"yearly leap", Budget{PeriodType: BudgetPeriodYearly, StartDate: "2024-01-01"}, "2024-01-01", "2024-12-31", "2024-01", 366},
+		{"custom", Budget{PeriodType: BudgetPeriodCustom, StartDate: "2024-03-10", EndDate: "2024-03-24"}, "2024-03-10", "2024-03-24", "2024-03", 15},
+		{"custom single day", Budget{PeriodType: BudgetPeriodCustom, StartDate: "2024-03-10", EndDate: "2024-03-10"}, "2024-03-10", "2024-03-10", "2024-03", 1},
+		{"monthly default", Budget{Month: "2024-02"}, "2024-02-01", "2024-02-29", "2024-02", 29},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := tt.budget
+			if err := normalizeBudgetPeriod(&budget, loc); err != nil {
+				t.Fatal(err)
+			}
+			if budget.StartDate != tt.wantStart || budget.EndDate != tt.wantEnd || budget.Month != tt.wantMonth {
+				t.Fatalf("period %s to %s in %s, want %s to %s in %s", budget.StartDate, budget.EndDate, budget.Month, tt.wantStart, tt.wantEnd, tt.wantMonth)
+			}
+
+			start, end, err := budgetWindow(&budget, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantEnd, _ := time.ParseInLocation(dateLayout, tt.wantEnd, loc)
+			if !end.Equal(wantEnd.AddDate(0, 0, 1)) {
+				t.Errorf("window ends %v, want the midnight after the last day", end)
+			}
+			if got := calendarDays(start, end); got != tt.wantDays {
+				t.Errorf("window covers %d days, want %d", got, tt.wantDays)
+			}
+		})
+	}
+}
+
+func TestNormalizeBudgetPeriodRejectsBadCustomDates(t *testing.T) {
+	for _, budget := range []Budget{
+		{PeriodType: BudgetPeriodCustom, StartDate: "2024-03-10", EndDate: "2024-03-09"},
+		{PeriodType: BudgetPeriodCustom, StartDate: "2024-03-10"},
+		{PeriodType: BudgetPeriodWeekly, StartDate: "10/03/2024"},
+		{PeriodType: "fortnightly", StartDate: "2024-03-10"},
+	} {
+		if err := normalizeBudgetPeriod(&budget, time.UTC); err == nil {
+			t.Errorf("%+v accepted, want an error", budget)
+		}
+	}
+}
+
+func TestCalendarDaysAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clocks went forward on 10 March 2024, so the week is an hour short of 7 × 24 hours
+	budget := Budget{PeriodType: BudgetPeriodWeekly, StartDate: "2024-03-07"}
+	if err := normalizeBudgetPeriod(&budget, loc); err != nil {
+		t.Fatal(err)
+	}
+	start, end, err := budgetWindow(&budget, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hours := end.Sub(start).Hours(); hours != 7*24-1 {
+		t.Fatalf("window is %v hours, want the short week of the DST change", hours)
+	}
+	if got := calendarDays(start, end); got != 7 {
+		t.Errorf("calendarDays = %d, want 7", got)
+	}
+
+	// And back on 3 November, making that day 25 hours long
+	from := time.Date(2024, 11, 3, 0, 0, 0, 0, loc)
+	to := time.Date(2024, 11, 4, 0, 0, 0, 0, loc)
+	if got := calendarDays(from, to); got != 1 {
+		t.Errorf("calendarDays over the 25-hour day = %d, want 1", got)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitStore counts hits per key within fixed windows. Implementations backed by a
+// shared store (e.g. Redis) let several server instances enforce the same limits.
+type RateLimitStore interface {
+	// Increment records a hit for key and returns the hit count in the current window and when it resets
+	Increment(key string, window time.Duration) (int, time.Time, error)
+}
+
+// AppRateLimitStore is the store used by the auth rate limits
+var AppRateLimitStore RateLimitStore = NewMemoryRateLimitStore()
+
+// rateLimitEntry is the hit counter of one key in a MemoryRateLimitStore
+type rateLimitEntry struct {
+	count   int
+	resetAt time.Time
+}
+
+// MemoryRateLimitStore keeps counters in process memory, suitable for a single instance
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	entries map[string]*rateLimitEntry
+	calls   int
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{entries: make(map[string]*rateLimitEntry)}
+}
+
+// Increment records a hit, starting a new window when the previous one has expired
+func (s *MemoryRateLimitStore) Increment(key string, window time.Duration) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	// Sweep expired entries now and then so the map does not grow without bound
+	s.calls++
+	if s.calls%1000 == 0 {
+		for k, e := range s.entries {
+			if now.After(e.resetAt) {
+				delete(s.entries, k)
+			}
+		}
+	}
+
+	entry, ok := s.entries[key]
+	if !ok || now.After(entry.resetAt) {
+		entry = &rateLimitEntry{resetAt: now.Add(window)}
+		s.entries[key] = entry
+	}
+	entry.count++
+	return entry.count, entry.resetAt, nil
+}
+
+// RateLimit allows at most limit requests per window for each key returned by keyFunc.
+// Requests for which keyFunc returns "" are not counted.
+func RateLimit(store RateLimitStore, name string, limit int, window time.Duration, keyFunc func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := keyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		count, resetAt, err := store.Increment(name+":"+key, window)
+		if err != nil {
+			// Fail open: an unavailable store should not lock every user out
+			c.Next()
+			return
+		}
+
+		remaining := limit - count
+		if remaining < 0 {
+			remaining = 0
+		}
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+
+		if count > limit {
+			retryAfter := int(time.Until(resetAt).Seconds()) + 1
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": T(c, "Too many requests, please try again later")})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// KeyByIP keys rate limits by the client IP address
+func KeyByIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// KeyByEmail keys rate limits by the "email" field of the JSON body, so that an account
+// is protected even when the attempts come from many addresses
+func KeyByEmail(c *gin.Context) string {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return ""
+	}
+	// Put the body back so the handler can still bind it
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(body, &input); err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(input.Email))
+}
+
+// KeyByChallengeUser keys rate limits by the user a two-factor challenge token was issued to, so
+// that second-step codes cannot be guessed from many addresses. Invalid tokens are not counted;
+// the handler rejects them.
+func KeyByChallengeUser(c *gin.Context) string {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return ""
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var input struct {
+		ChallengeToken string `json:"challenge_token"`
+	}
+	if err := json.Unmarshal(body, &input); err != nil || input.ChallengeToken == "" {
+		return ""
+	}
+	claims, err := parseToken(input.ChallengeToken)
+	if err != nil || claims["purpose"] != "2fa" {
+		return ""
+	}
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return ""
+	}
+	return strconv.FormatUint(uint64(userID), 10)
+}
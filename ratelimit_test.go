@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestTwoFactorAttemptsAreLimitedPerUser(t *testing.T) {
+	useTestKeys(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	limit := RateLimit(NewMemoryRateLimitStore(), "2fa-account", 2, time.Minute, KeyByChallengeUser)
+	router.POST("/login/2fa", limit, func(c *gin.Context) { c.Status(http.StatusUnauthorized) })
+
+	attempt := func(userID uint, ip string) int {
+		challenge, err := generateChallengeToken(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request := httptest.NewRequest(http.MethodPost, "/login/2fa", strings.NewReader(`{"challenge_token":"`+challenge+`","code":"000000"}`))
+		request.RemoteAddr = ip + ":1234"
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response.Code
+	}
+
+	// Fresh challenges from different addresses still count against the same user
+	for i, ip := range []string{"192.0.2.1", "192.0.2.2"} {
+		if code := attempt(1, ip); code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: status %d, want it to reach the handler", i+1, code)
+		}
+	}
+	if code := attempt(1, "192.0.2.3"); code != http.StatusTooManyRequests {
+		t.Fatalf("third attempt: status %d, want 429", code)
+	}
+	if code := attempt(2, "192.0.2.3"); code != http.StatusUnauthorized {
+		t.Fatalf("another user: status %d, want it to reach the handler", code)
+	}
+}
+
+func TestKeyByChallengeUserIgnoresOtherTokens(t *testing.T) {
+	useTestKeys(t)
+
+	session, err := generateToken(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 1, "purpose": "2fa"})
+	forgedToken, _ := forged.SignedString([]byte("not-the-key"))
+
+	for name, token := range map[string]string{"session token": session, "forged token": forgedToken, "empty": ""} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/login/2fa", strings.NewReader(`{"challenge_token":"`+token+`"}`))
+		if key := KeyByChallengeUser(c); key != "" {
+			t.Errorf("%s: key %q, want none", name, key)
+		}
+	}
+}
@@ -20,51 +20,184 @@ func SetupRouter() *gin.Engine {
 
 	// Configure CORS (Cross-Origin Resource Sharing) to allow frontend requests
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"https://gobudget.my.id", "http://localhost:3000"},   // Allowed origins
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},  // Allowed HTTP methods
-		AllowHeaders:     []string{"Authorization", "Content-Type", "Accept", "Cookie"}, // Allowed headers
-		ExposeHeaders:    []string{"Content-Length", "Set-Cookie"},                      // Exposed headers
-		AllowCredentials: true,                                                          // Allow sending cookies and authorization headers
-		MaxAge:           12 * time.Hour,                                                // Cache preflight request for 12 hours
+		AllowOrigins:     []string{"https://gobudget.my.id", "http://localhost:3000"},                                                   // Allowed origins
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},                                                  // Allowed HTTP methods
+		AllowHeaders:     []string{"Authorization", "Content-Type", "Accept", "Cookie", "If-Match", "If-None-Match", "Idempotency-Key"}, // Allowed headers
+		ExposeHeaders:    []string{"Content-Length", "Set-Cookie", "ETag", "Idempotent-Replayed"},                                       // Exposed headers
+		AllowCredentials: true,                                                                                                          // Allow sending cookies and authorization headers
+		MaxAge:           12 * time.Hour,                                                                                                // Cache preflight request for 12 hours
 	}))
 
+	// Throttle the unauthenticated endpoints that attackers can hammer
+	loginPerIP := RateLimit(AppRateLimitStore, "login-ip", 30, 15*time.Minute, KeyByIP)
+	loginPerAccount := RateLimit(AppRateLimitStore, "login-account", 10, 15*time.Minute, KeyByEmail)
+	twoFactorPerAccount := RateLimit(AppRateLimitStore, "2fa-account", 10, 15*time.Minute, KeyByChallengeUser)
+	registerPerIP := RateLimit(AppRateLimitStore, "register-ip", 5, time.Hour, KeyByIP)
+	recoveryPerIP := RateLimit(AppRateLimitStore, "recovery-ip", 10, time.Hour, KeyByIP)
+	recoveryPerAccount := RateLimit(AppRateLimitStore, "recovery-account", 3, time.Hour, KeyByEmail)
+
 	// Public routes (no authentication required)
 	public := r.Group("/")
 	{
-		public.POST("/register", Register) // User registration
-		public.POST("/login", Login)       // User login
+		public.GET("/.well-known/jwks.json", GetJWKS) // Public keys for verifying issued JWTs
+
+		public.POST("/register", registerPerIP, Register)                          // User registration
+		public.POST("/login", loginPerIP, loginPerAccount, Login)                  // User login
+		public.POST("/login/2fa", loginPerIP, twoFactorPerAccount, LoginTwoFactor) // Second login step for two-factor users
+
+		// Social login through OpenID Connect providers
+		public.GET("/auth/oidc/:provider/login", loginPerIP, OIDCLogin)       // Redirect to the identity provider
+		public.GET("/auth/oidc/:provider/callback", loginPerIP, OIDCCallback) // Complete the login and set the session cookie
+
+		// Account recovery and email verification
+		public.POST("/password/forgot", recoveryPerIP, recoveryPerAccount, ForgotPassword) // Email a password reset link
+		public.POST("/password/reset", recoveryPerIP, ResetPassword)                       // Set a new password with a reset token
+		public.POST("/email/verify", recoveryPerIP, VerifyEmail)                           // Confirm an email address with a verification token
+		public.POST("/user/restore", loginPerIP, loginPerAccount, RestoreUser)             // Cancel a pending account deletion
 	}
 
-	// Protected routes (authentication required)
+	// Create endpoints replay their first response when retried with the same Idempotency-Key
+	idempotent := Idempotent()
+
+	// Protected routes (authentication required, browser sessions only)
 	auth := r.Group("/")
-	auth.Use(AuthMiddleware()) // Apply authentication middleware
+	auth.Use(AuthMiddleware(), SessionOnly()) // Apply authentication middleware and refuse API tokens
 	{
-		auth.GET("/user", GetUser)   // Get user profile
-		auth.POST("/logout", Logout) // User logout
+		auth.GET("/user", GetUser)                          // Get user profile
+		auth.PUT("/user", UpdateUser)                       // Update name and email
+		auth.DELETE("/user", DeleteUser)                    // Delete the account (after a grace period)
+		auth.POST("/user/password", ChangePassword)         // Change password
+		auth.POST("/logout", Logout)                        // User logout
+		auth.POST("/email/resend", ResendVerificationEmail) // Resend the verification email
+
+		// Two-factor authentication (TOTP)
+		auth.POST("/user/2fa/enroll", EnrollTOTP)                      // Start enrollment and get the provisioning URI
+		auth.POST("/user/2fa/confirm", ConfirmTOTP)                    // Confirm with a code and receive recovery codes
+		auth.POST("/user/2fa/disable", DisableTOTP)                    // Turn off two-factor authentication
+		auth.POST("/user/2fa/recovery-codes", RegenerateRecoveryCodes) // Replace the recovery codes
+
+		// User preferences
+		auth.GET("/user/settings", GetUserSettings)    // Get timezone and locale
+		auth.PUT("/user/settings", UpdateUserSettings) // Update timezone and locale
+
+		// Audit log
+		auth.GET("/audit", GetAuditLog) // Get changes made by the user
 
+		// Categorization rules
+		auth.GET("/rules", GetRules)                // List rules in evaluation order
+		auth.POST("/rules", idempotent, CreateRule) // Create a rule
+		auth.PUT("/rules/:id", UpdateRule)          // Replace a rule
+		auth.DELETE("/rules/:id", DeleteRule)       // Delete a rule
+		auth.POST("/rules/apply", ApplyRules)       // Apply rules to existing transactions (supports dry runs)
+
+		// Trash (soft-deleted transactions and budgets)
+		auth.GET("/trash", GetTrash)                    // List deleted items
+		auth.DELETE("/trash", EmptyTrash)               // Permanently delete everything in the trash
+		auth.DELETE("/trash/:type/:id", PurgeTrashItem) // Permanently delete one item
+
+		// Personal access tokens
+		auth.GET("/user/tokens", GetPersonalTokens)                // List tokens
+		auth.POST("/user/tokens", idempotent, CreatePersonalToken) // Create a token
+		auth.DELETE("/user/tokens/:id", DeletePersonalToken)       // Revoke a token
+
+		// Budget management
+		auth.GET("/budgets", GetBudgets)                   // Get all budgets
+		auth.GET("/budgets/pace", GetBudgetPace)           // Spent-to-date versus expected-to-date of current budgets
+		auth.POST("/budgets", idempotent, CreateBudget)    // Create a new budget
+		auth.GET("/budgets/:id", GetBudgetByID)            // Get budget by ID
+		auth.PUT("/budgets/:id", UpdateBudget)             // Replace budget
+		auth.PATCH("/budgets/:id", PatchBudget)            // Update some budget fields
+		auth.PUT("/budgets/delete/:id", SoftDeleteBudget)  // Soft delete budget
+		auth.PUT("/budgets/restore/:id", RestoreBudget)    // Restore soft deleted budget
+		auth.GET("/budgets/:id/history", GetBudgetHistory) // Get prior versions of a budget
+		auth.POST("/budgets/:id/revert", RevertBudget)     // Revert a budget to an earlier version
+
+		// Budget templates (fixed amounts or percentages of income per category)
+		auth.GET("/budget-templates", GetBudgetTemplates)                     // List budget templates
+		auth.POST("/budget-templates", idempotent, CreateBudgetTemplate)      // Create a budget template
+		auth.PUT("/budget-templates/:id", UpdateBudgetTemplate)               // Replace a budget template
+		auth.DELETE("/budget-templates/:id", DeleteBudgetTemplate)            // Delete a budget template
+		auth.POST("/budgets/apply-template", idempotent, ApplyBudgetTemplate) // Generate a month's budgets from a template
+
+		// Envelopes (zero-based budgeting, opt-in through settings)
+		auth.GET("/envelopes", GetEnvelopes)                           // Money to be assigned and envelope balances for a month
+		auth.GET("/envelopes/ledger", GetEnvelopeLedger)               // Assignments and moves recorded for a month
+		auth.POST("/envelopes/assign", idempotent, AssignToEnvelope)   // Assign money from the pool to an envelope
+		auth.POST("/envelopes/move", idempotent, MoveBetweenEnvelopes) // Move money between envelopes
+	}
+
+	// Scope checks for routes that personal access tokens may also call
+	readTransactions := RequireScope(ScopeReadTransactions)
+	writeTransactions := RequireScope(ScopeWriteTransactions)
+	readReports := RequireScope(ScopeReadReports)
+
+	// Protected routes (authentication required, browser sessions or scoped API tokens)
+	api := r.Group("/")
+	api.Use(AuthMiddleware()) // Apply authentication middleware
+	{
 		// Transactions management
-		auth.GET("/transactions", GetTransactions)                  // Get all transactions
-		auth.POST("/transactions", CreateTransaction)               // Create a new transaction
-		auth.GET("/transactions/:id", GetTransactionByID)           // Get transaction by ID
-		auth.PUT("/transactions/:id", UpdateTransaction)            // Update transaction
-		auth.PUT("/transactions/delete/:id", SoftDeleteTransaction) // Soft delete transaction
-		auth.PUT("/transactions/restore/:id", RestoreTransaction)   // Restore soft deleted transaction
-
-		// Categories management
-		auth.POST("/categories", CreateCategory)                            // Create a new category
-		auth.GET("/categories", GetCategories)                              // Get all categories
-		auth.GET("/categories/:id/transactions", GetTransactionsByCategory) // Get transactions by category
+		api.GET("/transactions", readTransactions, GetTransactions)                     // Get all transactions
+		api.POST("/transactions", writeTransactions, idempotent, CreateTransaction)     // Create a new transaction
+		api.POST("/transactions/bulk", writeTransactions, idempotent, BulkTransactions) // Apply many changes atomically
+		api.GET("/transactions/suggest-category", readTransactions, SuggestCategory)    // Rank likely categories for a note
+		api.GET("/transactions/:id", readTransactions, GetTransactionByID)              // Get transaction by ID
+		api.PUT("/transactions/:id", writeTransactions, UpdateTransaction)              // Replace transaction
+		api.PATCH("/transactions/:id", writeTransactions, PatchTransaction)             // Update some transaction fields
+		api.PUT("/transactions/delete/:id", writeTransactions, SoftDeleteTransaction)   // Soft delete transaction
+		api.PUT("/transactions/restore/:id", writeTransactions, RestoreTransaction)     // Restore soft deleted transaction
+		api.GET("/transactions/:id/history", readTransactions, GetTransactionHistory)   // Get prior versions of a transaction
+		api.POST("/transactions/:id/revert", writeTransactions, RevertTransaction)      // Revert a transaction to an earlier version
+
+		// Categories
+		api.GET("/categories", readTransactions, GetCategories)                              // Get all categories
+		api.GET("/categories/:id/transactions", readTransactions, GetTransactionsByCategory) // Get transactions by category
+
+		// Payees (canonical merchants and payers)
+		api.GET("/payees", readTransactions, GetPayees)                                  // List payees with their aliases
+		api.POST("/payees", writeTransactions, idempotent, CreatePayee)                  // Create a payee
+		api.PUT("/payees/:id", writeTransactions, UpdatePayee)                           // Rename a payee
+		api.DELETE("/payees/:id", writeTransactions, DeletePayee)                        // Delete a payee, unlinking its transactions
+		api.POST("/payees/:id/aliases", writeTransactions, idempotent, CreatePayeeAlias) // Map another raw description to a payee
+		api.DELETE("/payees/:id/aliases/:alias_id", writeTransactions, DeletePayeeAlias) // Remove an alias
 
 		// Summary (Financial overview)
-		auth.GET("/summary", GetSummary) // Get financial summary
+		api.GET("/summary", readReports, GetSummary) // Get financial summary
 
-		// Budget management
-		auth.GET("/budgets", GetBudgets)                  // Get all budgets
-		auth.POST("/budgets", CreateBudget)               // Create a new budget
-		auth.GET("/budgets/:id", GetBudgetByID)           // Get budget by ID
-		auth.PUT("/budgets/:id", UpdateBudget)            // Update budget
-		auth.PUT("/budgets/delete/:id", SoftDeleteBudget) // Soft delete budget
-		auth.PUT("/budgets/restore/:id", RestoreBudget)   // Restore soft deleted budget
+		// Reports
+		api.GET("/reports/payees", readReports, GetPayeeReport)                   // Totals per payee over a period
+		api.GET("/reports/budget-variance", readReports, GetBudgetVarianceReport) // Budgeted versus actual per category and month
+
+		// Insights
+		api.GET("/insights/anomalies", readReports, GetAnomalies)                      // Transactions flagged as unusual
+		api.POST("/insights/anomalies/:id/dismiss", writeTransactions, DismissAnomaly) // Mark a flag as reviewed
+
+		// Exchange rates
+		api.GET("/exchange-rates", readTransactions, GetExchangeRates) // Get system exchange rates
+	}
+
+	// Admin routes (authentication and the admin role required)
+	admin := r.Group("/admin")
+	admin.Use(AuthMiddleware(), SessionOnly(), RequireRole(RoleAdmin))
+	{
+		// User management
+		admin.GET("/users", AdminGetUsers)                // List and search users
+		admin.GET("/users/:id/stats", AdminGetUserStats)  // Get per-user usage statistics
+		admin.PUT("/users/disable/:id", AdminDisableUser) // Disable a user
+		admin.PUT("/users/restore/:id", AdminRestoreUser) // Re-enable or undelete a user
+		admin.PUT("/users/role/:id", AdminSetUserRole)    // Change a user's role
+
+		// Audit log
+		admin.GET("/audit", AdminGetAuditLog) // Get changes made by any user
+
+		// System categories
+		admin.POST("/categories", CreateCategory)            // Create a new category
+		admin.PUT("/categories/:id", AdminUpdateCategory)    // Rename a category
+		admin.DELETE("/categories/:id", AdminDeleteCategory) // Delete an unused category
+
+		// Exchange rates
+		admin.GET("/exchange-rates", GetExchangeRates)                     // Get all exchange rates
+		admin.PUT("/exchange-rates/:currency", AdminSetExchangeRate)       // Create or update a rate
+		admin.DELETE("/exchange-rates/:currency", AdminDeleteExchangeRate) // Delete a rate
 	}
 
 	return r
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AuditEntityRule identifies categorization rules in the audit log
+const AuditEntityRule = "rule"
+
+// Transaction fields a rule condition can test
+const (
+	RuleFieldNote     = "note"
+	RuleFieldAmount   = "amount" // In the transaction's own currency
+	RuleFieldType     = "type"
+	RuleFieldCurrency = "currency"
+)
+
+// Rule condition operators; text fields compare case-insensitively
+const (
+	RuleOpEquals     = "equals"
+	RuleOpContains   = "contains"
+	RuleOpStartsWith = "starts_with"
+	RuleOpGreater    = "gt"
+	RuleOpGreaterEq  = "gte"
+	RuleOpLess       = "lt"
+	RuleOpLessEq     = "lte"
+)
+
+// ruleOperators lists the operators each field accepts
+var ruleOperators = map[string][]string{
+	RuleFieldNote:     {RuleOpEquals, RuleOpContains, RuleOpStartsWith},
+	RuleFieldAmount:   {RuleOpEquals, RuleOpGreater, RuleOpGreaterEq, RuleOpLess, RuleOpLessEq},
+	RuleFieldType:     {RuleOpEquals},
+	RuleFieldCurrency: {RuleOpEquals},
+}
+
+// RuleCondition is one test a transaction must pass for a rule to match
+type RuleCondition struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"` // Amounts are given as decimal strings, e.g. "1000000"
+}
+
+// matches reports whether the transaction passes the condition
+func (cond RuleCondition) matches(t *Transaction) bool {
+	if cond.Field == RuleFieldAmount {
+		value, err := strconv.ParseFloat(cond.Value, 64)
+		if err != nil {
+			return false
+		}
+		switch cond.Operator {
+		case RuleOpEquals:
+			return t.Amount == value
+		case RuleOpGreater:
+			return t.Amount > value
+		case RuleOpGreaterEq:
+			return t.Amount >= value
+		case RuleOpLess:
+			return t.Amount < value
+		case RuleOpLessEq:
+			return t.Amount <= value
+		}
+		return false
+	}
+
+	var actual string
+	switch cond.Field {
+	case RuleFieldNote:
+		actual = t.Note
+	case RuleFieldType:
+		actual = t.Type
+	case RuleFieldCurrency:
+		actual = t.Currency
+	}
+	actual, expected := strings.ToLower(actual), strings.ToLower(cond.Value)
+
+	switch cond.Operator {
+	case RuleOpEquals:
+		return actual == expected
+	case RuleOpContains:
+		return strings.Contains(actual, expected)
+	case RuleOpStartsWith:
+		return strings.HasPrefix(actual, expected)
+	}
+	return false
+}
+
+// matches reports whether the transaction passes every condition of the rule
+func (rule Rule) matches(t *Transaction) bool {
+	for _, cond := range rule.Conditions {
+		if !cond.matches(t) {
+			return false
+		}
+	}
+	return len(rule.Conditions) > 0
+}
+
+// ruleOutcome is what the user's rules decide for one transaction
+type ruleOutcome struct {
+	CategoryID *uint    `json:"category_id"`
+	Tags       []string `json:"tags"`
+	RuleIDs    []uint   `json:"rule_ids"` // Rules that matched, in the order they were applied
+}
+
+// evaluateRules runs the rules against a transaction in priority order. The first matching rule
+// with a category decides the category, tags from every matching rule are combined, and a rule
+// marked StopProcessing ends the evaluation.
+func evaluateRules(rules []Rule, t *Transaction) ruleOutcome {
+	outcome := ruleOutcome{}
+	seenTags := make(map[string]bool)
+	for _, rule := range rules {
+		if !rule.matches(t) {
+			continue
+		}
+		outcome.RuleIDs = append(outcome.RuleIDs, rule.ID)
+		if outcome.CategoryID == nil && rule.CategoryID != nil {
+			outcome.CategoryID = rule.CategoryID
+		}
+		for _, tag := range rule.Tags {
+			if !seenTags[strings.ToLower(tag)] {
+				seenTags[strings.ToLower(tag)] = true
+				outcome.Tags = append(outcome.Tags, tag)
+			}
+		}
+		if rule.StopProcessing {
+			break
+		}
+	}
+	return outcome
+}
+
+// loadRules returns the user's enabled rules in the order they are evaluated
+func loadRules(tx *gorm.DB, userID uint) ([]Rule, error) {
+	var rules []Rule
+	err := tx.Where("user_id = ? AND enabled", userID).Order("priority ASC, id ASC").Find(&rules).Error
+	return rules, err
+}
+
+// applyRulesOnCreate fills in a new transaction's category from the user's rules, unless the client
+// chose one, and returns the tags the rules add. Call it before the transaction is inserted.
+func applyRulesOnCreate(tx *gorm.DB, t *Transaction) ([]string, error) {
+	rules, err := loadRules(tx, t.UserID)
+	if err != nil {
+		return nil, err
+	}
+	outcome := evaluateRules(rules, t)
+	if t.CategoryID == nil {
+		t.CategoryID = outcome.CategoryID
+	}
+	return outcome.Tags, nil
+}
+
+// attachTags links a transaction to the user's tags with the given names, creating missing tags
+func attachTags(tx *gorm.DB, c *gin.Context, t *Transaction, names []string) error {
+	for _, name := range names {
+		tag, err := findOrCreateTag(tx, c, t.UserID, name)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(t).Omit("Tags.*").Association("Tags").Append(&tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateRule checks a rule's conditions and actions before it is stored
+func validateRule(rule *Rule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(rule.Conditions) == 0 {
+		return errors.New("at least one condition is required")
+	}
+	for i, cond := range rule.Conditions {
+		operators, ok := ruleOperators[cond.Field]
+		if !ok {
+			return fmt.Errorf("condition %d: unknown field %q", i+1, cond.Field)
+		}
+		if !containsString(operators, cond.Operator) {
+			return fmt.Errorf("condition %d: operator %q is not supported for %s", i+1, cond.Operator, cond.Field)
+		}
+		if cond.Field == RuleFieldAmount {
+			if _, err := strconv.ParseFloat(cond.Value, 64); err != nil {
+				return fmt.Errorf("condition %d: %q is not a number", i+1, cond.Value)
+			}
+		}
+	}
+
+	if rule.CategoryID != nil && *rule.CategoryID == 0 {
+		rule.CategoryID = nil
+	}
+	for i, tag := range rule.Tags {
+		rule.Tags[i] = strings.TrimSpace(tag)
+		if rule.Tags[i] == "" || len(rule.Tags[i]) > maxTagLength {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+	}
+	if rule.CategoryID == nil && len(rule.Tags) == 0 {
+		return errors.New("a rule needs a category or at least one tag")
+	}
+	if rule.CategoryID != nil {
+		var count int64
+		DB.Model(&Category{}).Where("id = ?", *rule.CategoryID).Count(&count)
+		if count == 0 {
+			return errors.New("category not found")
+		}
+	}
+	return nil
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleInput is the body for creating or replacing a rule
+type ruleInput struct {
+	Name           string          `json:"name" binding:"required"`
+	Priority       int             `json:"priority"` // Lower runs first
+	Enabled        *bool           `json:"enabled"`  // Defaults to true
+	Conditions     []RuleCondition `json:"conditions" binding:"required"`
+	CategoryID     *uint           `json:"category_id"`
+	Tags           []string        `json:"tags"`
+	StopProcessing bool            `json:"stop_processing"`
+}
+
+// apply copies the input onto the rule and validates it
+func (input ruleInput) apply(rule *Rule) error {
+	rule.Name = input.Name
+	rule.Priority = input.Priority
+	rule.Enabled = input.Enabled == nil || *input.Enabled
+	rule.Conditions = input.Conditions
+	rule.CategoryID = input.CategoryID
+	rule.Tags = input.Tags
+	if rule.Tags == nil {
+		rule.Tags = []string{}
+	}
+	rule.StopProcessing = input.StopProcessing
+	return validateRule(rule)
+}
+
+// GetRules lists the user's rules in evaluation order
+func GetRules(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var rules []Rule
+	if err := DB.Where("user_id = ?", userID).Order("priority ASC, id ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch rules")})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// CreateRule adds a categorization rule
+func CreateRule(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var input ruleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := Rule{UserID: userID.(uint)}
+	if err := input.apply(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&rule).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionCreate, AuditEntityRule, rule.ID, nil, rule)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to create rule")})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateRule replaces a rule's conditions and actions
+func UpdateRule(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var rule Rule
+	if err := DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&rule).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Rule not found")})
+		return
+	}
+
+	var input ruleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := rule
+	if err := input.apply(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&rule).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionUpdate, AuditEntityRule, rule.ID, before, rule)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update rule")})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRule removes a rule; transactions it already categorized keep their category
+func DeleteRule(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var rule Rule
+	if err := DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&rule).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Rule not found")})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&rule).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionDelete, AuditEntityRule, rule.ID, rule, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to delete rule")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Rule deleted")})
+}
+
+// ruleChange describes what applying the rules does, or would do, to one existing transaction
+type ruleChange struct {
+	TransactionID uint     `json:"transaction_id"`
+	Note          string   `json:"note"`
+	RuleIDs       []uint   `json:"rule_ids"`
+	CategoryFrom  *uint    `json:"category_from"`
+	CategoryTo    *uint    `json:"category_to"`
+	AddedTags     []string `json:"added_tags"`
+}
+
+// ApplyRules runs the user's rules over existing transactions. With dry_run it only reports the
+// changes; otherwise they are saved in one database transaction. By default only uncategorized
+// transactions get a category; overwrite lets rules replace categories chosen earlier.
+func ApplyRules(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var input struct {
+		Filter    bulkFilter `json:"filter"` // Same filter as POST /transactions/bulk
+		DryRun    bool       `json:"dry_run"`
+		Overwrite bool       `json:"overwrite"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	rules, err := loadRules(DB, userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch rules")})
+		return
+	}
+
+	changes := []ruleChange{}
+	var updated []*Transaction
+	loc := userLocation(userID.(uint))
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		query, err := bulkFilterQuery(tx, userID.(uint), loc, &input.Filter)
+		if err != nil {
+			return &bulkItemError{http.StatusBadRequest, err.Error()}
+		}
+
+		var transactions []Transaction
+		if err := query.Preload("Tags").Order("occurred_at DESC").Find(&transactions).Error; err != nil {
+			return err
+		}
+
+		for i := range transactions {
+			transaction := &transactions[i]
+			change, changed := planRuleChange(rules, transaction, input.Overwrite)
+			if !changed {
+				continue
+			}
+			changes = append(changes, change)
+			if input.DryRun {
+				continue
+			}
+
+			before := *transaction
+			transaction.CategoryID = change.CategoryTo
+			transaction.Version++
+			if err := saveIfVersion(tx, transaction, before.Version); err != nil {
+				return err
+			}
+			if err := retrainClassifier(tx, &before, transaction); err != nil {
+				return err
+			}
+			if err := attachTags(tx, c, transaction, change.AddedTags); err != nil {
+				return err
+			}
+			if err := recordAudit(tx, c, AuditActionUpdate, AuditEntityTransaction, transaction.ID, before, transaction); err != nil {
+				return err
+			}
+			updated = append(updated, transaction)
+		}
+		return nil
+	})
+
+	var itemErr *bulkItemError
+	if errors.As(err, &itemErr) {
+		c.JSON(itemErr.status, gin.H{"error": itemErr.message})
+		return
+	}
+	if errors.Is(err, errVersionConflict) {
+		preconditionFailed(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to apply rules")})
+		return
+	}
+	flagAnomalies(updated)
+
+	c.JSON(http.StatusOK, gin.H{"dry_run": input.DryRun, "changed": len(changes), "changes": changes})
+}
+
+// planRuleChange works out how the rules would change an existing transaction
+func planRuleChange(rules []Rule, t *Transaction, overwrite bool) (ruleChange, bool) {
+	outcome := evaluateRules(rules, t)
+	change := ruleChange{
+		TransactionID: t.ID,
+		Note:          t.Note,
+		RuleIDs:       outcome.RuleIDs,
+		CategoryFrom:  t.CategoryID,
+		CategoryTo:    t.CategoryID,
+		AddedTags:     []string{},
+	}
+
+	if outcome.CategoryID != nil && (t.CategoryID == nil || overwrite) {
+		change.CategoryTo = outcome.CategoryID
+	}
+	for _, name := range outcome.Tags {
+		tagged := false
+		for _, existing := range t.Tags {
+			if strings.EqualFold(existing.Name, name) {
+				tagged = true
+				break
+			}
+		}
+		if !tagged {
+			change.AddedTags = append(change.AddedTags, name)
+		}
+	}
+
+	categoryChanged := (change.CategoryFrom == nil) != (change.CategoryTo == nil) ||
+		(change.CategoryFrom != nil && *change.CategoryFrom != *change.CategoryTo)
+	return change, categoryChanged || len(change.AddedTags) > 0
+}
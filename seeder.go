@@ -14,7 +14,7 @@ func SeedDatabase() {
 	DB.Model(&User{}).Count(&count)
 	if count == 0 {
 		users := []User{
-			{Name: "User 1", Email: "user1@example.com", Password: "$2a$10$Ys8ik7V3EU.KlFZa7trJ8uSqKDsj.WGNrYu2xsAil1yT3mC.k4hwy"},
+			{Name: "User 1", Email: "user1@example.com", Role: RoleAdmin, Password: "$2a$10$Ys8ik7V3EU.KlFZa7trJ8uSqKDsj.WGNrYu2xsAil1yT3mC.k4hwy"},
 		}
 		DB.Create(&users)
 		log.Println("✅ Users seeded!")
@@ -36,30 +36,41 @@ func SeedDatabase() {
 		log.Println("✅ Categories seeded!")
 	}
 
+	// ✅ Seed Exchange Rates
+	DB.Model(&ExchangeRate{}).Count(&count)
+	if count == 0 {
+		rates := []ExchangeRate{
+			{Currency: "IDR", RateToIDR: 1},
+			{Currency: "USD", RateToIDR: 16500},
+		}
+		DB.Create(&rates)
+		log.Println("✅ Exchange rates seeded!")
+	}
+
 	// ✅ Seed Transactions
 	DB.Model(&Transaction{}).Count(&count)
 	if count == 0 {
 		transactions := []Transaction{
 			// Income transactions
-			{Type: "Income", Amount: randomAmount(4000, 6000), Currency: "USD", ExchangeRate: 16500, Note: "Last month's salary", CategoryID: getCategoryID("Salary"), UserID: 1, CreatedAt: time.Now().AddDate(0, -6, 0)},
-			{Type: "Income", Amount: randomAmount(4000, 6000), Currency: "USD", ExchangeRate: 16500, Note: "This month's salary", CategoryID: getCategoryID("Salary"), UserID: 1, CreatedAt: time.Now().AddDate(0, -5, 0)},
-			{Type: "Income", Amount: randomAmount(500, 2000), Currency: "USD", ExchangeRate: 16500, Note: "Bonus", CategoryID: getCategoryID("Investment"), UserID: 1, CreatedAt: time.Now().AddDate(0, -3, 0)},
+			{Type: "Income", Amount: randomAmount(4000, 6000), Currency: "USD", ExchangeRate: 16500, Note: "Last month's salary", CategoryID: getCategoryID("Salary"), UserID: 1, CreatedAt: time.Now().AddDate(0, -6, 0), OccurredAt: time.Now().AddDate(0, -6, 0)},
+			{Type: "Income", Amount: randomAmount(4000, 6000), Currency: "USD", ExchangeRate: 16500, Note: "This month's salary", CategoryID: getCategoryID("Salary"), UserID: 1, CreatedAt: time.Now().AddDate(0, -5, 0), OccurredAt: time.Now().AddDate(0, -5, 0)},
+			{Type: "Income", Amount: randomAmount(500, 2000), Currency: "USD", ExchangeRate: 16500, Note: "Bonus", CategoryID: getCategoryID("Investment"), UserID: 1, CreatedAt: time.Now().AddDate(0, -3, 0), OccurredAt: time.Now().AddDate(0, -3, 0)},
 
 			// Expense - Food
-			{Type: "Expense", Amount: randomAmount(50, 150), Currency: "USD", ExchangeRate: 16500, Note: "Lunch", CategoryID: getCategoryID("Food"), UserID: 1, CreatedAt: time.Now().AddDate(0, -6, 0)},
-			{Type: "Expense", Amount: randomAmount(70, 200), Currency: "USD", ExchangeRate: 16500, Note: "Dinner outside", CategoryID: getCategoryID("Food"), UserID: 1, CreatedAt: time.Now().AddDate(0, -4, 0)},
+			{Type: "Expense", Amount: randomAmount(50, 150), Currency: "USD", ExchangeRate: 16500, Note: "Lunch", CategoryID: getCategoryID("Food"), UserID: 1, CreatedAt: time.Now().AddDate(0, -6, 0), OccurredAt: time.Now().AddDate(0, -6, 0)},
+			{Type: "Expense", Amount: randomAmount(70, 200), Currency: "USD", ExchangeRate: 16500, Note: "Dinner outside", CategoryID: getCategoryID("Food"), UserID: 1, CreatedAt: time.Now().AddDate(0, -4, 0), OccurredAt: time.Now().AddDate(0, -4, 0)},
 
 			// Expense - Transportation
-			{Type: "Expense", Amount: randomAmount(100, 300), Currency: "USD", ExchangeRate: 16500, Note: "Motorbike fuel", CategoryID: getCategoryID("Transportation"), UserID: 1, CreatedAt: time.Now().AddDate(0, -5, 0)},
-			{Type: "Expense", Amount: randomAmount(50, 250), Currency: "USD", ExchangeRate: 16500, Note: "Train ticket", CategoryID: getCategoryID("Transportation"), UserID: 1, CreatedAt: time.Now().AddDate(0, -2, 0)},
+			{Type: "Expense", Amount: randomAmount(100, 300), Currency: "USD", ExchangeRate: 16500, Note: "Motorbike fuel", CategoryID: getCategoryID("Transportation"), UserID: 1, CreatedAt: time.Now().AddDate(0, -5, 0), OccurredAt: time.Now().AddDate(0, -5, 0)},
+			{Type: "Expense", Amount: randomAmount(50, 250), Currency: "USD", ExchangeRate: 16500, Note: "Train ticket", CategoryID: getCategoryID("Transportation"), UserID: 1, CreatedAt: time.Now().AddDate(0, -2, 0), OccurredAt: time.Now().AddDate(0, -2, 0)},
 
 			// Expense - Bills
-			{Type: "Expense", Amount: randomAmount(300, 700), Currency: "USD", ExchangeRate: 16500, Note: "Electricity bill", CategoryID: getCategoryID("Bills"), UserID: 1, CreatedAt: time.Now().AddDate(0, -5, 0)},
-			{Type: "Expense", Amount: randomAmount(100, 500), Currency: "USD", ExchangeRate: 16500, Note: "Monthly internet", CategoryID: getCategoryID("Bills"), UserID: 1, CreatedAt: time.Now().AddDate(0, -3, 0)},
+			{Type: "Expense", Amount: randomAmount(300, 700), Currency: "USD", ExchangeRate: 16500, Note: "Electricity bill", CategoryID: getCategoryID("Bills"), UserID: 1, CreatedAt: time.Now().AddDate(0, -5, 0), OccurredAt: time.Now().AddDate(0, -5, 0)},
+			{Type: "Expense", Amount: randomAmount(100, 500), Currency: "USD", ExchangeRate: 16500, Note: "Monthly internet", CategoryID: getCategoryID("Bills"), UserID: 1, CreatedAt: time.Now().AddDate(0, -3, 0), OccurredAt: time.Now().AddDate(0, -3, 0)},
 
 			// Expense - Entertainment
-			{Type: "Expense", Amount: randomAmount(50, 300), Currency: "USD", ExchangeRate: 16500, Note: "Movie night", CategoryID: getCategoryID("Entertainment"), UserID: 1, CreatedAt: time.Now().AddDate(0, -4, 0)},
-			{Type: "Expense", Amount: randomAmount(150, 500), Currency: "USD", ExchangeRate: 16500, Note: "Online games", CategoryID: getCategoryID("Entertainment"), UserID: 1, CreatedAt: time.Now().AddDate(0, -1, 0)},
+			{Type: "Expense", Amount: randomAmount(50, 300), Currency: "USD", ExchangeRate: 16500, Note: "Movie night", CategoryID: getCategoryID("Entertainment"), UserID: 1, CreatedAt: time.Now().AddDate(0, -4, 0), OccurredAt: time.Now().AddDate(0, -4, 0)},
+			{Type: "Expense", Amount: randomAmount(150, 500), Currency: "USD", ExchangeRate: 16500, Note: "Online games", CategoryID: getCategoryID("Entertainment"), UserID: 1, CreatedAt: time.Now().AddDate(0, -1, 0), OccurredAt: time.Now().AddDate(0, -1, 0)},
 		}
 
 		// Filter transactions with valid CategoryID
@@ -92,6 +103,7 @@ func SeedDatabase() {
 		var validBudgets []Budget
 		for _, b := range budgets {
 			if b.CategoryID > 0 {
+				normalizeBudgetPeriod(&b, time.Local)
 				validBudgets = append(validBudgets, b)
 			} else {
 				log.Printf("⚠️ Budget for category ID %v was not saved because CategoryID is nil!", b.CategoryID)
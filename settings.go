@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// defaultTimezone is used for users who have not saved a timezone yet
+const defaultTimezone = "UTC"
+
+// loadUserSettings returns the user's saved settings, or the defaults if none exist
+func loadUserSettings(userID uint) UserSettings {
+	settings := UserSettings{UserID: userID, Timezone: defaultTimezone, Locale: defaultLocale}
+	DB.Where("user_id = ?", userID).First(&settings)
+	return settings
+}
+
+// userLocation returns the time.Location that the user's reports are evaluated in
+func userLocation(userID uint) *time.Location {
+	loc, err := time.LoadLocation(loadUserSettings(userID).Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// GetUserSettings retrieves the authenticated user's preferences
+func GetUserSettings(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	c.JSON(http.StatusOK, loadUserSettings(userID.(uint)))
+}
+
+// UpdateUserSettings saves the authenticated user's timezone and locale, and optionally
+// whether they get anomaly alerts by email and use envelope budgeting
+func UpdateUserSettings(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var input struct {
+		Timezone          string `json:"timezone" binding:"required"`
+		Locale            string `json:"locale" binding:"required"`
+		AnomalyAlerts     *bool  `json:"anomaly_alerts"`     // Left unchanged when omitted
+		EnvelopeBudgeting *bool  `json:"envelope_budgeting"` // Left unchanged when omitted
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := time.LoadLocation(input.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Invalid timezone")})
+		return
+	}
+	if !isSupportedLocale(input.Locale) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Unsupported locale")})
+		return
+	}
+
+	settings := loadUserSettings(userID.(uint))
+	before := settings
+	settings.Timezone = input.Timezone
+	settings.Locale = input.Locale
+	if input.AnomalyAlerts != nil {
+		settings.AnomalyAlerts = *input.AnomalyAlerts
+	}
+	if input.EnvelopeBudgeting != nil {
+		settings.EnvelopeBudgeting = *input.EnvelopeBudgeting
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&settings).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionUpdate, AuditEntitySettings, settings.UserID, before, settings)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update settings")})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
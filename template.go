@@ -0,0 +1,390 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AuditEntityBudgetTemplate identifies budget templates in the audit log
+const AuditEntityBudgetTemplate = "budget_template"
+
+// maxBudgetTemplateNameLength matches the size of BudgetTemplate.Name
+const maxBudgetTemplateNameLength = 100
+
+// validateBudgetTemplate checks a template's name and lines. Each category appears at most once,
+// and percentage lines may not add up to more than all of the income.
+func validateBudgetTemplate(template *BudgetTemplate) error {
+	template.Name = strings.TrimSpace(template.Name)
+	if template.Name == "" || len(template.Name) > maxBudgetTemplateNameLength {
+		return errors.New("name is required and must be at most 100 characters")
+	}
+	if len(template.Lines) == 0 {
+		return errors.New("at least one line is required")
+	}
+
+	seen := make(map[uint]bool)
+	totalPercent := 0.0
+	for i, line := range template.Lines {
+		if seen[line.CategoryID] {
+			return fmt.Errorf("line %d: category %d appears more than once", i+1, line.CategoryID)
+		}
+		seen[line.CategoryID] = true
+
+		if line.Percent != 0 {
+			if line.Percent < 0 || line.Percent > 100 {
+				return fmt.Errorf("line %d: percent must be between 0 and 100", i+1)
+			}
+			if line.Amount != 0 {
+				return fmt.Errorf("line %d: set either an amount or a percent, not both", i+1)
+			}
+			totalPercent += line.Percent
+			continue
+		}
+		if line.Amount <= 0 {
+			return fmt.Errorf("line %d: amount must be greater than zero", i+1)
+		}
+		if strings.TrimSpace(line.Currency) == "" {
+			return fmt.Errorf("line %d: currency is required", i+1)
+		}
+		if line.ExchangeRate <= 0 {
+			return fmt.Errorf("line %d: exchange_rate must be greater than zero", i+1)
+		}
+	}
+	if totalPercent > 100 {
+		return errors.New("percentages add up to more than 100")
+	}
+
+	categoryIDs := make([]uint, 0, len(seen))
+	for categoryID := range seen {
+		categoryIDs = append(categoryIDs, categoryID)
+	}
+	var count int64
+	DB.Model(&Category{}).Where("id IN ?", categoryIDs).Count(&count)
+	if int(count) != len(categoryIDs) {
+		return errors.New("category not found")
+	}
+	return nil
+}
+
+// budgetTemplateInput is the body for creating or replacing a budget template
+type budgetTemplateInput struct {
+	Name  string               `json:"name" binding:"required"`
+	Lines []BudgetTemplateLine `json:"lines" binding:"required"`
+}
+
+// apply copies the input onto the template and validates it
+func (input budgetTemplateInput) apply(template *BudgetTemplate) error {
+	template.Name = input.Name
+	template.Lines = input.Lines
+	return validateBudgetTemplate(template)
+}
+
+// applyTo sets a budget's limit from the template line, given the month's income in IDR
+func (line BudgetTemplateLine) applyTo(b *Budget, income float64) {
+	if line.Percent == 0 {
+		b.Amount = line.Amount
+		b.Currency = line.Currency
+		b.ExchangeRate = line.ExchangeRate
+		b.Percent = nil
+		return
+	}
+	percent := line.Percent
+	b.Amount = income * percent / 100
+	b.Currency = "IDR"
+	b.ExchangeRate = 1
+	b.Percent = &percent
+}
+
+// monthIncome sums the income (in IDR) recorded in [start, end)
+func monthIncome(tx *gorm.DB, userID uint, start, end time.Time) (float64, error) {
+	var income sql.NullFloat64
+	err := tx.Model(&Transaction{}).
+		Where("user_id = ? AND type = ? AND deleted_at IS NULL", userID, "Income").
+		Where("occurred_at >= ? AND occurred_at < ?", start, end).
+		Select("COALESCE(SUM(amount * exchange_rate), 0)").
+		Scan(&income).Error
+	return income.Float64, err
+}
+
+// refreshPercentBudgets recomputes the percentage-of-income budgets of the month an income
+// transaction falls in. Call it in the database transaction that adds, deletes or restores the income.
+func refreshPercentBudgets(tx *gorm.DB, c *gin.Context, t *Transaction, loc *time.Location) error {
+	if t.Type != "Income" {
+		return nil
+	}
+
+	month := t.OccurredAt.In(loc).Format("2006-01")
+	var budgets []Budget
+	if err := tx.Where("user_id = ? AND month = ? AND period_type = ? AND percent IS NOT NULL", t.UserID, month, BudgetPeriodMonthly).Find(&budgets).Error; err != nil {
+		return err
+	}
+	if len(budgets) == 0 {
+		return nil
+	}
+
+	start, end, err := monthRange(month, loc)
+	if err != nil {
+		return err
+	}
+	income, err := monthIncome(tx, t.UserID, start, end)
+	if err != nil {
+		return err
+	}
+
+	for _, budget := range budgets {
+		amount := income * *budget.Percent / 100
+		if amount == budget.Amount {
+			continue
+		}
+		before := budget
+		if err := updateIfVersion(tx, &budget, before.Version, map[string]interface{}{"amount": amount, "version": before.Version + 1}); err != nil {
+			return err
+		}
+		budget.Amount = amount
+		budget.Version++
+		if err := recordAudit(tx, c, AuditActionUpdate, AuditEntityBudget, budget.ID, before, budget); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshPercentBudgetsForEdit recomputes the percentage-of-income budgets an edited transaction
+// affects: the month it is in now and, for income that moved to another month or stopped being
+// income, the month it left. Call it in the database transaction that saves the edit.
+func refreshPercentBudgetsForEdit(tx *gorm.DB, c *gin.Context, before, after *Transaction, loc *time.Location) error {
+	if err := refreshPercentBudgets(tx, c, after, loc); err != nil {
+		return err
+	}
+	moved := before.OccurredAt.In(loc).Format("2006-01") != after.OccurredAt.In(loc).Format("2006-01")
+	if before.Type == "Income" && (after.Type != "Income" || moved) {
+		return refreshPercentBudgets(tx, c, before, loc)
+	}
+	return nil
+}
+
+// GetBudgetTemplates lists the user's budget templates
+func GetBudgetTemplates(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var templates []BudgetTemplate
+	if err := DB.Where("user_id = ?", userID).Order("name ASC").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch budget templates")})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// CreateBudgetTemplate adds a budget template
+func CreateBudgetTemplate(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var input budgetTemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template := BudgetTemplate{UserID: userID.(uint)}
+	if err := input.apply(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&template).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionCreate, AuditEntityBudgetTemplate, template.ID, nil, template)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to create budget template")})
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// UpdateBudgetTemplate replaces a budget template's name and lines. Budgets already generated
+// from it are left as they are until the template is applied again.
+func UpdateBudgetTemplate(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var template BudgetTemplate
+	if err := DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&template).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Budget template not found")})
+		return
+	}
+
+	var input budgetTemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := template
+	if err := input.apply(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&template).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionUpdate, AuditEntityBudgetTemplate, template.ID, before, template)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to update budget template")})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// DeleteBudgetTemplate removes a budget template; budgets generated from it are kept
+func DeleteBudgetTemplate(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var template BudgetTemplate
+	if err := DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&template).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Budget template not found")})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&template).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionDelete, AuditEntityBudgetTemplate, template.ID, template, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to delete budget template")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Budget template deleted")})
+}
+
+// ApplyBudgetTemplate generates a month's budgets (?month=YYYY-MM, default the current month)
+// from a template. A category that already has a budget that month gets its limit replaced;
+// percentage lines are computed from the income recorded so far and follow new income as it arrives.
+func ApplyBudgetTemplate(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var input struct {
+		TemplateID uint `json:"template_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loc := userLocation(userID.(uint))
+	month := c.Query("month")
+	if month == "" {
+		month = time.Now().In(loc).Format("2006-01")
+	}
+	start, end, err := monthRange(month, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var template BudgetTemplate
+	if err := DB.Where("id = ? AND user_id = ?", input.TemplateID, userID).First(&template).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Budget template not found")})
+		return
+	}
+
+	var income float64
+	var budgets []Budget
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if income, err = monthIncome(tx, userID.(uint), start, end); err != nil {
+			return err
+		}
+
+		for _, line := range template.Lines {
+			var budget Budget
+			err := tx.Where("user_id = ? AND category_id = ? AND month = ? AND period_type = ?", userID, line.CategoryID, month, BudgetPeriodMonthly).First(&budget).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			if err != nil {
+				budget = Budget{UserID: userID.(uint), CategoryID: line.CategoryID, Month: month, PeriodType: BudgetPeriodMonthly, Version: 1}
+				if err := normalizeBudgetPeriod(&budget, loc); err != nil {
+					return err
+				}
+				line.applyTo(&budget, income)
+				if err := tx.Create(&budget).Error; err != nil {
+					return err
+				}
+				if err := recordAudit(tx, c, AuditActionCreate, AuditEntityBudget, budget.ID, nil, budget); err != nil {
+					return err
+				}
+			} else {
+				before := budget
+				line.applyTo(&budget, income)
+				budget.Version++
+				if err := saveIfVersion(tx, &budget, before.Version); err != nil {
+					return err
+				}
+				if err := recordAudit(tx, c, AuditActionUpdate, AuditEntityBudget, budget.ID, before, budget); err != nil {
+					return err
+				}
+			}
+			budgets = append(budgets, budget)
+		}
+		return nil
+	})
+	if errors.Is(err, errVersionConflict) {
+		preconditionFailed(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to apply budget template")})
+		return
+	}
+
+	for i := range budgets {
+		DB.Preload("Category").First(&budgets[i], budgets[i].ID)
+		spent, err := budgetSpent(&budgets[i], loc)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch total spent data")})
+			return
+		}
+		budgets[i].Spent = spent
+	}
+
+	c.JSON(http.StatusOK, gin.H{"month": month, "income": income, "budgets": budgets})
+}
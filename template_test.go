@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefreshPercentBudgetsFollowsIncomeBetweenMonths(t *testing.T) {
+	setupTestDB(t, &Transaction{}, &Budget{}, &AuditLog{})
+
+	percent := 10.0
+	budgets := []Budget{
+		{UserID: 1, CategoryID: 1, Currency: "IDR", ExchangeRate: 1, Percent: &percent, Month: "2024-03", PeriodType: BudgetPeriodMonthly, StartDate: "2024-03-01", EndDate: "2024-03-31", Version: 1},
+		{UserID: 1, CategoryID: 1, Currency: "IDR", ExchangeRate: 1, Percent: &percent, Month: "2024-04", PeriodType: BudgetPeriodMonthly, StartDate: "2024-04-01", EndDate: "2024-04-30", Version: 1},
+	}
+	if err := DB.Create(&budgets).Error; err != nil {
+		t.Fatal(err)
+	}
+	amounts := func() (float64, float64) {
+		var march, april Budget
+		DB.First(&march, budgets[0].ID)
+		DB.First(&april, budgets[1].ID)
+		return march.Amount, april.Amount
+	}
+
+	income := Transaction{UserID: 1, Type: "Income", Amount: 1000000, Currency: "IDR", ExchangeRate: 1, OccurredAt: time.Date(2024, 3, 25, 9, 0, 0, 0, time.UTC), Version: 1}
+	if err := DB.Create(&income).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := refreshPercentBudgets(DB, nil, &income, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	if march, april := amounts(); march != 100000 || april != 0 {
+		t.Fatalf("after creating March income: budgets %v and %v, want 100000 and 0", march, april)
+	}
+
+	// Moving the income to April updates both months
+	before := income
+	income.OccurredAt = time.Date(2024, 4, 1, 9, 0, 0, 0, time.UTC)
+	income.Amount = 2000000
+	if err := DB.Model(&Transaction{}).Where("id = ?", income.ID).Updates(map[string]interface{}{"occurred_at": income.OccurredAt, "amount": income.Amount}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := refreshPercentBudgetsForEdit(DB, nil, &before, &income, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	if march, april := amounts(); march != 0 || april != 200000 {
+		t.Fatalf("after moving the income: budgets %v and %v, want 0 and 200000", march, april)
+	}
+
+	// Deleting the income takes it out again
+	if err := DB.Delete(&income).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := refreshPercentBudgets(DB, nil, &income, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	if _, april := amounts(); april != 0 {
+		t.Fatalf("after deleting the income: April budget %v, want 0", april)
+	}
+}
+
+func TestBudgetPatchKeepsPercentBudgetsInIDR(t *testing.T) {
+	percent := 20.0
+	budget := Budget{Amount: 100000, Currency: "IDR", ExchangeRate: 1, Percent: &percent, Month: "2024-03"}
+
+	patch := budgetPatch{Currency: Optional[string]{Set: true, Value: "USD"}}
+	if err := patch.apply(&budget, time.UTC); err == nil {
+		t.Fatal("changing the currency of a percentage budget was accepted")
+	}
+
+	// Setting an amount turns it into a fixed budget, which may use any currency
+	budget = Budget{Amount: 100000, Currency: "IDR", ExchangeRate: 1, Percent: &percent, Month: "2024-03"}
+	patch = budgetPatch{
+		Amount:       Optional[float64]{Set: true, Value: 50},
+		Currency:     Optional[string]{Set: true, Value: "USD"},
+		ExchangeRate: Optional[float64]{Set: true, Value: 16000},
+	}
+	if err := patch.apply(&budget, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	if budget.Percent != nil || budget.Currency != "USD" {
+		t.Fatalf("budget = %+v, want a fixed USD budget", budget)
+	}
+}
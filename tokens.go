@@ -0,0 +1,189 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// personalTokenPrefix marks personal access tokens so AuthMiddleware can tell them from JWTs
+const personalTokenPrefix = "gbp_"
+
+// Scopes that can be granted to personal access tokens
+const (
+	ScopeReadTransactions  = "read:transactions"
+	ScopeWriteTransactions = "write:transactions"
+	ScopeReadReports       = "read:reports"
+)
+
+// validScopes lists every scope a token may be created with
+var validScopes = map[string]bool{
+	ScopeReadTransactions:  true,
+	ScopeWriteTransactions: true,
+	ScopeReadReports:       true,
+}
+
+// authenticatePersonalToken resolves a personal access token to its record, recording its use.
+// It returns nil if the token is unknown or expired.
+func authenticatePersonalToken(raw string) *PersonalAccessToken {
+	var token PersonalAccessToken
+	if err := DB.Where("token_hash = ?", hashToken(raw)).First(&token).Error; err != nil {
+		return nil
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return nil
+	}
+
+	// Only record usage once a minute so busy scripts don't write on every request
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > time.Minute {
+		DB.Model(&token).Update("last_used_at", now)
+	}
+	return &token
+}
+
+// RequireScope restricts a route to browser sessions and personal access tokens granted scope
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if scopes, isToken := c.Get("tokenScopes"); isToken {
+			granted := false
+			for _, s := range scopes.([]string) {
+				if s == scope {
+					granted = true
+					break
+				}
+			}
+			if !granted {
+				c.JSON(http.StatusForbidden, gin.H{"error": T(c, "Token lacks the required scope"), "required_scope": scope})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// SessionOnly refuses personal access tokens, for routes that no scope covers
+func SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isToken := c.Get("tokenScopes"); isToken {
+			c.JSON(http.StatusForbidden, gin.H{"error": T(c, "This endpoint is not available to API tokens")})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetPersonalTokens lists the authenticated user's personal access tokens
+func GetPersonalTokens(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var tokens []PersonalAccessToken
+	if err := DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch tokens")})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreatePersonalToken mints a named, scoped token; its value is only ever shown in this response
+func CreatePersonalToken(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var input struct {
+		Name          string   `json:"name" binding:"required"`
+		Scopes        []string `json:"scopes" binding:"required,min=1"`
+		ExpiresInDays int      `json:"expires_in_days" binding:"min=0"` // 0 means the token never expires
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, scope := range input.Scopes {
+		if !validScopes[scope] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Unknown scope"), "scope": scope})
+			return
+		}
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to generate token")})
+		return
+	}
+	raw := personalTokenPrefix + secret
+
+	token := PersonalAccessToken{
+		UserID:    userID.(uint),
+		Name:      input.Name,
+		Prefix:    raw[:len(personalTokenPrefix)+8],
+		TokenHash: hashToken(raw),
+		Scopes:    input.Scopes,
+	}
+	if input.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, input.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&token).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionCreate, AuditEntityToken, token.ID, nil, token)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to generate token")})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"token": raw, "details": token})
+}
+
+// DeletePersonalToken revokes one of the authenticated user's personal access tokens
+func DeletePersonalToken(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	var token PersonalAccessToken
+	if err := DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&token).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Token not found")})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&token).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, AuditActionDelete, AuditEntityToken, token.ID, token, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to revoke token")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Token revoked")})
+}
+
+// isPersonalToken reports whether a bearer credential is a personal access token rather than a JWT
+func isPersonalToken(credential string) bool {
+	return strings.HasPrefix(credential, personalTokenPrefix)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of RFC 6238 Appendix B, "12345678901234567890" in ASCII
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+// fixClock makes clock return t for the rest of the test
+func fixClock(t *testing.T, at time.Time) {
+	t.Helper()
+	previous := clock
+	clock = func() time.Time { return at }
+	t.Cleanup(func() { clock = previous })
+}
+
+func TestTOTPCodeMatchesRFC6238Vectors(t *testing.T) {
+	// Appendix B lists 8-digit codes; a 6-digit code is the same value modulo 10^6
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},          // 94287082
+		{1111111109, "081804"},  // 07081804
+		{1111111111, "050471"},  // 14050471
+		{1234567890, "005924"},  // 89005924
+		{2000000000, "279037"},  // 69279037
+		{20000000000, "353130"}, // 65353130
+	}
+
+	for _, v := range vectors {
+		fixClock(t, time.Unix(v.unix, 0).UTC())
+		code, err := totpCode(rfc6238Secret, clock())
+		if err != nil {
+			t.Fatalf("totpCode at %d: %v", v.unix, err)
+		}
+		if code != v.code {
+			t.Errorf("totpCode at %d = %s, want %s", v.unix, code, v.code)
+		}
+		if counter, ok := validateTOTP(rfc6238Secret, v.code, clock()); !ok || counter != v.unix/totpPeriod {
+			t.Errorf("validateTOTP at %d = (%d, %v), want (%d, true)", v.unix, counter, ok, v.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTOTPAllowsOneStepOfSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0).UTC()
+	fixClock(t, now)
+	current := totpCounter(now)
+
+	for step := int64(-3); step <= 3; step++ {
+		code, err := hotp(rfc6238Secret, current+step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counter, ok := validateTOTP(rfc6238Secret, code, clock())
+		wantOK := step >= -totpSkew && step <= totpSkew
+		if ok != wantOK {
+			t.Errorf("code from step %+d accepted = %v, want %v", step, ok, wantOK)
+		}
+		if ok && counter != current+step {
+			t.Errorf("code from step %+d matched counter %d, want %d", step, counter, current+step)
+		}
+	}
+}
+
+func TestValidateTOTPRejectsMalformedCodes(t *testing.T) {
+	fixClock(t, time.Unix(59, 0).UTC())
+	for _, code := range []string{"", "28708", "2870820", "abcdef"} {
+		if _, ok := validateTOTP(rfc6238Secret, code, clock()); ok {
+			t.Errorf("validateTOTP accepted %q", code)
+		}
+	}
+}
+
+func TestVerifySecondFactorRejectsReuse(t *testing.T) {
+	setupTestDB(t, &User{}, &RecoveryCode{})
+	fixClock(t, time.Unix(1234567890, 0).UTC())
+
+	user := User{Email: "totp@example.com", TOTPSecret: rfc6238Secret, TOTPEnabled: true}
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	codes, err := generateRecoveryCodes(DB, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	// A recovery code works once, also when typed in lower case with separators
+	if err := verifySecondFactor(DB, &user, codes[0]); err != nil {
+		t.Fatalf("first use of a recovery code: %v", err)
+	}
+	if err := verifySecondFactor(DB, &user, codes[0]); err != errInvalidSecondFactor {
+		t.Errorf("second use of a recovery code = %v, want errInvalidSecondFactor", err)
+	}
+	relaxed := strings.ToLower(codes[1][:4] + "-" + codes[1][4:])
+	if err := verifySecondFactor(DB, &user, relaxed); err != nil {
+		t.Errorf("recovery code %q: %v", relaxed, err)
+	}
+
+	// So does a TOTP code: the same time step cannot be replayed
+	code, _ := totpCode(rfc6238Secret, clock())
+	if err := verifySecondFactor(DB, &user, code); err != nil {
+		t.Fatalf("first use of a TOTP code: %v", err)
+	}
+	if err := verifySecondFactor(DB, &user, code); err != errInvalidSecondFactor {
+		t.Errorf("replayed TOTP code = %v, want errInvalidSecondFactor", err)
+	}
+}
+
+func TestRandomRecoveryCodeIsUniform(t *testing.T) {
+	// 256 is not a multiple of the 31 symbols, so reducing a byte modulo 31 would draw the first
+	// 8 symbols about 12% more often than the rest; a uniform draw stays within a few percent
+	const draws = 20000
+	counts := make(map[rune]int)
+	for i := 0; i < draws; i++ {
+		code, err := randomRecoveryCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != 11 || code[5] != '-' {
+			t.Fatalf("recovery code %q, want XXXXX-XXXXX", code)
+		}
+		for _, r := range strings.ReplaceAll(code, "-", "") {
+			if !strings.ContainsRune(recoveryCodeAlphabet, r) {
+				t.Fatalf("recovery code %q uses %q, which is not in the alphabet", code, r)
+			}
+			counts[r]++
+		}
+	}
+
+	expected := float64(draws*10) / float64(len(recoveryCodeAlphabet))
+	for _, r := range recoveryCodeAlphabet {
+		if deviation := (float64(counts[r]) - expected) / expected; deviation > 0.06 || deviation < -0.06 {
+			t.Errorf("symbol %q drawn %d times, expected about %.0f", r, counts[r], expected)
+		}
+	}
+}
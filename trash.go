@@ -0,0 +1,268 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// trashPurgeBatchSize limits how many rows the retention job purges per database transaction
+const trashPurgeBatchSize = 500
+
+// trashRetention is how long soft-deleted items stay in the trash; zero keeps them until purged by hand
+func trashRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || days < 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// TrashItem is a soft-deleted transaction or budget as listed in the trash
+type TrashItem struct {
+	Type        string       `json:"type"` // "transaction" or "budget"
+	ID          uint         `json:"id"`
+	DeletedAt   time.Time    `json:"deleted_at"`
+	PurgeAt     *time.Time   `json:"purge_at"` // When the retention job deletes it for good; null if never
+	Transaction *Transaction `json:"transaction,omitempty"`
+	Budget      *Budget      `json:"budget,omitempty"`
+}
+
+// GetTrash lists the user's soft-deleted transactions and budgets, most recently deleted first
+func GetTrash(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	itemType := c.Query("type")
+	if itemType != "" && itemType != AuditEntityTransaction && itemType != AuditEntityBudget {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Unknown item type")})
+		return
+	}
+
+	var transactions []Transaction
+	var budgets []Budget
+	if itemType != AuditEntityBudget {
+		if err := DB.Unscoped().Preload("Category").Preload("Tags").Where("user_id = ? AND deleted_at IS NOT NULL", userID).Find(&transactions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch trash")})
+			return
+		}
+	}
+	if itemType != AuditEntityTransaction {
+		if err := DB.Unscoped().Preload("Category").Where("user_id = ? AND deleted_at IS NOT NULL", userID).Find(&budgets).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch trash")})
+			return
+		}
+	}
+
+	retention := trashRetention()
+	items := make([]TrashItem, 0, len(transactions)+len(budgets))
+	for i := range transactions {
+		items = append(items, newTrashItem(AuditEntityTransaction, transactions[i].ID, transactions[i].DeletedAt.Time, retention))
+		items[len(items)-1].Transaction = &transactions[i]
+	}
+	for i := range budgets {
+		items = append(items, newTrashItem(AuditEntityBudget, budgets[i].ID, budgets[i].DeletedAt.Time, retention))
+		items[len(items)-1].Budget = &budgets[i]
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+
+	c.JSON(http.StatusOK, items)
+}
+
+// newTrashItem builds a trash entry, working out when retention will purge it
+func newTrashItem(itemType string, id uint, deletedAt time.Time, retention time.Duration) TrashItem {
+	item := TrashItem{Type: itemType, ID: id, DeletedAt: deletedAt}
+	if retention > 0 {
+		purgeAt := deletedAt.Add(retention)
+		item.PurgeAt = &purgeAt
+	}
+	return item
+}
+
+// PurgeTrashItem permanently deletes one soft-deleted transaction or budget
+func PurgeTrashItem(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	query := DB.Unscoped().Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", c.Param("id"), userID)
+	var err error
+	switch c.Param("type") {
+	case AuditEntityTransaction:
+		var transaction Transaction
+		if query.First(&transaction).Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Transaction not found")})
+			return
+		}
+		err = DB.Transaction(func(tx *gorm.DB) error { return purgeTransactions(tx, c, []Transaction{transaction}) })
+	case AuditEntityBudget:
+		var budget Budget
+		if query.First(&budget).Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": T(c, "Budget not found")})
+			return
+		}
+		err = DB.Transaction(func(tx *gorm.DB) error { return purgeBudgets(tx, c, []Budget{budget}) })
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Unknown item type")})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to delete item")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Item permanently deleted")})
+}
+
+// EmptyTrash permanently deletes everything in the user's trash, or only one type with ?type=
+func EmptyTrash(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	itemType := c.Query("type")
+	if itemType != "" && itemType != AuditEntityTransaction && itemType != AuditEntityBudget {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Unknown item type")})
+		return
+	}
+
+	purged := 0
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if itemType != AuditEntityBudget {
+			var transactions []Transaction
+			if err := tx.Unscoped().Where("user_id = ? AND deleted_at IS NOT NULL", userID).Find(&transactions).Error; err != nil {
+				return err
+			}
+			if err := purgeTransactions(tx, c, transactions); err != nil {
+				return err
+			}
+			purged += len(transactions)
+		}
+		if itemType != AuditEntityTransaction {
+			var budgets []Budget
+			if err := tx.Unscoped().Where("user_id = ? AND deleted_at IS NOT NULL", userID).Find(&budgets).Error; err != nil {
+				return err
+			}
+			if err := purgeBudgets(tx, c, budgets); err != nil {
+				return err
+			}
+			purged += len(budgets)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to empty trash")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Trash emptied"), "purged": purged})
+}
+
+// purgeTransactions permanently deletes soft-deleted transactions along with their tag links
+func purgeTransactions(tx *gorm.DB, c *gin.Context, transactions []Transaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(transactions))
+	for i, transaction := range transactions {
+		ids[i] = transaction.ID
+	}
+	if err := tx.Exec("DELETE FROM transaction_tag WHERE transaction_id IN ?", ids).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("transaction_id IN ?", ids).Delete(&TransactionAnomaly{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Delete(&Transaction{}, ids).Error; err != nil {
+		return err
+	}
+
+	for _, transaction := range transactions {
+		if err := recordAudit(tx, c, AuditActionPurge, AuditEntityTransaction, transaction.ID, transaction, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeBudgets permanently deletes soft-deleted budgets
+func purgeBudgets(tx *gorm.DB, c *gin.Context, budgets []Budget) error {
+	if len(budgets) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(budgets))
+	for i, budget := range budgets {
+		ids[i] = budget.ID
+	}
+	if err := tx.Unscoped().Delete(&Budget{}, ids).Error; err != nil {
+		return err
+	}
+
+	for _, budget := range budgets {
+		if err := recordAudit(tx, c, AuditActionPurge, AuditEntityBudget, budget.ID, budget, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeExpiredTrash hard-deletes transactions and budgets that have been in the trash longer than
+// the retention period, in batches so a large backlog does not hold one long database transaction
+func purgeExpiredTrash() {
+	retention := trashRetention()
+	if retention == 0 {
+		return
+	}
+	cutoff := time.Now().Add(-retention)
+
+	purged := 0
+	for {
+		var transactions []Transaction
+		if err := DB.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Limit(trashPurgeBatchSize).Find(&transactions).Error; err != nil {
+			log.Println("Failed to list expired transactions:", err)
+			return
+		}
+		if err := DB.Transaction(func(tx *gorm.DB) error { return purgeTransactions(tx, nil, transactions) }); err != nil {
+			log.Println("Failed to purge expired transactions:", err)
+			return
+		}
+		purged += len(transactions)
+		if len(transactions) < trashPurgeBatchSize {
+			break
+		}
+	}
+	for {
+		var budgets []Budget
+		if err := DB.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Limit(trashPurgeBatchSize).Find(&budgets).Error; err != nil {
+			log.Println("Failed to list expired budgets:", err)
+			return
+		}
+		if err := DB.Transaction(func(tx *gorm.DB) error { return purgeBudgets(tx, nil, budgets) }); err != nil {
+			log.Println("Failed to purge expired budgets:", err)
+			return
+		}
+		purged += len(budgets)
+		if len(budgets) < trashPurgeBatchSize {
+			break
+		}
+	}
+
+	if purged > 0 {
+		log.Printf("Purged %d expired trash items", purged)
+	}
+}
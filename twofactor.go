@@ -0,0 +1,370 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// AuditActionRegenerateRecoveryCodes records that a user replaced their recovery codes
+const AuditActionRegenerateRecoveryCodes = "regenerate_recovery_codes"
+
+// recoveryCodeCount is how many recovery codes are issued at a time
+const recoveryCodeCount = 10
+
+// recoveryCodeAlphabet avoids characters that are easy to misread (0/O, 1/I/L)
+const recoveryCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// errInvalidSecondFactor is returned when neither a TOTP nor a recovery code matches
+var errInvalidSecondFactor = errors.New("invalid two-factor code")
+
+// normalizeRecoveryCode strips separators and case so "abcd-efgh" matches "ABCDEFGH"
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// randomRecoveryCode draws a code of the form XXXXX-XXXXX. rand.Int picks each character
+// uniformly; reducing a random byte modulo the alphabet size would favour the first few.
+func randomRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(recoveryCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = recoveryCodeAlphabet[n.Int64()]
+	}
+	return string(b[:5]) + "-" + string(b[5:]), nil
+}
+
+// generateRecoveryCodes replaces the user's recovery codes and returns the new plaintext codes
+func generateRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := randomRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		rows = append(rows, RecoveryCode{UserID: userID, CodeHash: hashToken(normalizeRecoveryCode(code))})
+	}
+
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// verifySecondFactor accepts a current TOTP code or an unused recovery code for the user.
+// Accepted TOTP steps and recovery codes are recorded so neither can be used twice.
+func verifySecondFactor(tx *gorm.DB, user *User, code string) error {
+	if counter, ok := validateTOTP(user.TOTPSecret, code, clock()); ok {
+		result := tx.Model(&User{}).
+			Where("id = ? AND totp_last_counter < ?", user.ID, counter).
+			Update("totp_last_counter", counter)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errInvalidSecondFactor
+		}
+		return nil
+	}
+
+	result := tx.Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(normalizeRecoveryCode(code))).
+		Update("used_at", clock())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return errInvalidSecondFactor
+	}
+	return nil
+}
+
+// currentUser loads the authenticated user, writing an error response if that fails
+func currentUser(c *gin.Context) (*User, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return nil, false
+	}
+
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": T(c, "User not found")})
+		return nil, false
+	}
+	return &user, true
+}
+
+// EnrollTOTP generates a pending TOTP secret and returns it with its provisioning URI
+func EnrollTOTP(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": T(c, "Two-factor authentication is already enabled")})
+		return
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to generate secret")})
+		return
+	}
+
+	// The secret stays pending until ConfirmTOTP proves the authenticator app has it
+	if err := DB.Model(user).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_counter": 0}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to generate secret")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":           secret,
+		"provisioning_uri": totpProvisioningURI(secret, user.Email),
+	})
+}
+
+// ConfirmTOTP enables two-factor authentication once the user proves their app generates valid codes
+func ConfirmTOTP(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": T(c, "Two-factor authentication is already enabled")})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Two-factor enrollment has not been started")})
+		return
+	}
+
+	counter, valid := validateTOTP(user.TOTPSecret, input.Code, clock())
+	if !valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid two-factor code")})
+		return
+	}
+
+	var codes []string
+	before := *user
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{"totp_enabled": true, "totp_last_counter": counter}).Error; err != nil {
+			return err
+		}
+		user.TOTPEnabled = true
+		user.TOTPLastCounter = counter
+		if err := recordAudit(tx, c, AuditActionUpdate, AuditEntityUser, user.ID, before, *user); err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = generateRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to enable two-factor authentication")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        T(c, "Two-factor authentication enabled"),
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTOTP turns off two-factor authentication after checking the password and a second factor
+func DisableTOTP(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"` // TOTP or recovery code
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Two-factor authentication is not enabled")})
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid credentials")})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := verifySecondFactor(tx, user, input.Code); err != nil {
+			return err
+		}
+		before := *user
+		if err := tx.Model(user).Updates(map[string]interface{}{"totp_enabled": false, "totp_secret": "", "totp_last_counter": 0}).Error; err != nil {
+			return err
+		}
+		user.TOTPEnabled, user.TOTPSecret, user.TOTPLastCounter = false, "", 0
+		if err := recordAudit(tx, c, AuditActionUpdate, AuditEntityUser, user.ID, before, *user); err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&RecoveryCode{}).Error
+	})
+	if errors.Is(err, errInvalidSecondFactor) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid two-factor code")})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to disable two-factor authentication")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Two-factor authentication disabled")})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a current TOTP code
+func RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Two-factor authentication is not enabled")})
+		return
+	}
+
+	var codes []string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := verifySecondFactor(tx, user, input.Code); err != nil {
+			return err
+		}
+		if err := recordAudit(tx, c, AuditActionRegenerateRecoveryCodes, AuditEntityUser, user.ID, nil, nil); err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = generateRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if errors.Is(err, errInvalidSecondFactor) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid two-factor code")})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to generate recovery codes")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// LoginTwoFactor completes a two-factor login and issues the session cookie
+func LoginTwoFactor(c *gin.Context) {
+	var input struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+		Code           string `json:"code" binding:"required"` // TOTP or recovery code
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": T(c, "Invalid request")})
+		return
+	}
+
+	// The challenge token proves the password step succeeded within the last few minutes
+	claims, err := parseToken(input.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid token")})
+		return
+	}
+	userIDFloat, ok := claims["user_id"].(float64)
+	if purpose, _ := claims["purpose"].(string); purpose != "2fa" || !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid token")})
+		return
+	}
+
+	var user User
+	if err := DB.First(&user, uint(userIDFloat)).Error; err != nil || !user.TOTPEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid token")})
+		return
+	}
+
+	if user.DisabledAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": T(c, "Account disabled")})
+		return
+	}
+
+	// Wrong codes count towards the same lockout as wrong passwords
+	if isLockedOut(&user) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": T(c, "Too many requests, please try again later")})
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		return verifySecondFactor(tx, &user, input.Code)
+	})
+	if errors.Is(err, errInvalidSecondFactor) {
+		recordFailedLogin(&user)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Invalid two-factor code")})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to verify two-factor code")})
+		return
+	}
+
+	token, err := generateToken(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to generate token")})
+		return
+	}
+
+	resetFailedLogins(&user)
+	setAuthCookie(c, token)
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "Login successful"), "recovery_codes_remaining": remainingRecoveryCodes(user.ID)})
+}
+
+// remainingRecoveryCodes counts the user's unused recovery codes
+func remainingRecoveryCodes(userID uint) int64 {
+	var count int64
+	DB.Model(&RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count)
+	return count
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxVarianceMonths limits how many months one budget variance report covers
+const maxVarianceMonths = 36
+
+// VarianceAmounts compares a budgeted amount with actual spending, in IDR. Variance is actual minus
+// budgeted, so a positive variance means spending went over budget.
+type VarianceAmounts struct {
+	Budgeted        float64  `json:"budgeted"`
+	Actual          float64  `json:"actual"`
+	Variance        float64  `json:"variance"`
+	VariancePercent *float64 `json:"variance_percent"` // Variance as a percentage of the budget; null when nothing was budgeted
+}
+
+// BudgetVariance is the variance of one category in one month
+type BudgetVariance struct {
+	Month    string   `json:"month"`
+	Category Category `json:"category"`
+	VarianceAmounts
+}
+
+// OverBudgetCategory is a category that went over budget in more than one month of the report
+type OverBudgetCategory struct {
+	Category Category `json:"category"`
+	Months   []string `json:"months"`
+}
+
+// newVarianceAmounts fills in the variance of a budgeted and actual amount
+func newVarianceAmounts(budgeted, actual float64) VarianceAmounts {
+	amounts := VarianceAmounts{Budgeted: budgeted, Actual: actual, Variance: actual - budgeted}
+	if budgeted > 0 {
+		percent := amounts.Variance / budgeted * 100
+		amounts.VariancePercent = &percent
+	}
+	return amounts
+}
+
+// varianceMonths reads ?from= and ?to= (YYYY-MM) and returns the first and last month of the
+// report. Both default to the current month, and from defaults to to when only to is given.
+func varianceMonths(c *gin.Context, loc *time.Location) (time.Time, time.Time, error) {
+	now := time.Now().In(loc)
+	to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01", value, loc)
+		if err != nil {
+			return to, to, errors.New("invalid to month, expected YYYY-MM")
+		}
+		to = parsed
+	}
+	from := to
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01", value, loc)
+		if err != nil {
+			return from, to, errors.New("invalid from month, expected YYYY-MM")
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		return from, to, errors.New("to month must not be before from month")
+	}
+	if !from.AddDate(0, maxVarianceMonths, 0).After(to) {
+		return from, to, errors.New("the report can cover at most 36 months")
+	}
+	return from, to, nil
+}
+
+// GetBudgetVarianceReport compares monthly budgets with actual spending for every category and
+// month from ?from= to ?to= (YYYY-MM). Spending in a category without a budget that month is
+// reported with a budget of zero. Budgets of other period types do not map onto months and are
+// left out.
+func GetBudgetVarianceReport(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": T(c, "Unauthorized")})
+		return
+	}
+
+	loc := userLocation(userID.(uint))
+	from, to, err := varianceMonths(c, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	fromMonth, toMonth := from.Format("2006-01"), to.Format("2006-01")
+
+	type cell struct {
+		Month      string
+		CategoryID uint
+		Amount     float64
+	}
+
+	var budgeted []cell
+	err = DB.Model(&Budget{}).
+		Select("month, category_id, SUM(amount * exchange_rate) AS amount").
+		Where("user_id = ? AND period_type = ? AND month >= ? AND month <= ?", userID, BudgetPeriodMonthly, fromMonth, toMonth).
+		Group("month, category_id").
+		Scan(&budgeted).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch budget variance report")})
+		return
+	}
+
+	// Group by month as seen from the user's timezone rather than the database session's
+	var actual []cell
+	err = DB.Model(&Transaction{}).
+		Select("TO_CHAR(occurred_at AT TIME ZONE ?, 'YYYY-MM') AS month, category_id, SUM(amount * exchange_rate) AS amount", loadUserSettings(userID.(uint)).Timezone).
+		Where("user_id = ? AND type = ? AND category_id IS NOT NULL AND deleted_at IS NULL", userID, "Expense").
+		Where("occurred_at >= ? AND occurred_at < ?", from, to.AddDate(0, 1, 0)).
+		Group("month, category_id").
+		Scan(&actual).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch budget variance report")})
+		return
+	}
+
+	type key struct {
+		month      string
+		categoryID uint
+	}
+	amounts := make(map[key][2]float64) // Budgeted, actual
+	categoryIDs := make(map[uint]bool)
+	for _, row := range budgeted {
+		k := key{row.Month, row.CategoryID}
+		amounts[k] = [2]float64{row.Amount, amounts[k][1]}
+		categoryIDs[row.CategoryID] = true
+	}
+	for _, row := range actual {
+		k := key{row.Month, row.CategoryID}
+		amounts[k] = [2]float64{amounts[k][0], row.Amount}
+		categoryIDs[row.CategoryID] = true
+	}
+
+	categories := make(map[uint]Category)
+	if len(categoryIDs) > 0 {
+		ids := make([]uint, 0, len(categoryIDs))
+		for id := range categoryIDs {
+			ids = append(ids, id)
+		}
+		var found []Category
+		if err := DB.Where("id IN ?", ids).Find(&found).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": T(c, "Failed to fetch budget variance report")})
+			return
+		}
+		for _, category := range found {
+			categories[category.ID] = category
+		}
+	}
+
+	rows := make([]BudgetVariance, 0, len(amounts))
+	var totalBudgeted, totalActual float64
+	for k, amount := range amounts {
+		category, ok := categories[k.categoryID]
+		if !ok {
+			category = Category{ID: k.categoryID}
+		}
+		rows = append(rows, BudgetVariance{Month: k.month, Category: category, VarianceAmounts: newVarianceAmounts(amount[0], amount[1])})
+		totalBudgeted += amount[0]
+		totalActual += amount[1]
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Month != rows[j].Month {
+			return rows[i].Month < rows[j].Month
+		}
+		return rows[i].Category.Name < rows[j].Category.Name
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":                   fromMonth,
+		"to":                     toMonth,
+		"rows":                   rows,
+		"totals":                 newVarianceAmounts(totalBudgeted, totalActual),
+		"over_budget_categories": overBudgetCategories(rows),
+	})
+}
+
+// overBudgetCategories picks out the categories that spent more than a non-zero budget in at least
+// two months of the report, those with the most such months first
+func overBudgetCategories(rows []BudgetVariance) []OverBudgetCategory {
+	overMonths := make(map[uint][]string)
+	categories := make(map[uint]Category)
+	for _, row := range rows {
+		if row.Budgeted > 0 && row.Actual > row.Budgeted {
+			overMonths[row.Category.ID] = append(overMonths[row.Category.ID], row.Month)
+			categories[row.Category.ID] = row.Category
+		}
+	}
+
+	overBudget := []OverBudgetCategory{}
+	for categoryID, months := range overMonths {
+		if len(months) < 2 {
+			continue
+		}
+		sort.Strings(months)
+		overBudget = append(overBudget, OverBudgetCategory{Category: categories[categoryID], Months: months})
+	}
+	sort.Slice(overBudget, func(i, j int) bool {
+		if len(overBudget[i].Months) != len(overBudget[j].Months) {
+			return len(overBudget[i].Months) > len(overBudget[j].Months)
+		}
+		return overBudget[i].Category.Name < overBudget[j].Category.Name
+	})
+	return overBudget
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVarianceMonthsBounds(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	thisMonth := time.Now().UTC().Format("2006-01")
+
+	tests := []struct {
+		query    string
+		wantFrom string
+		wantTo   string
+		wantErr  bool
+	}{
+		{"", thisMonth, thisMonth, false},
+		{"?to=2024-05", "2024-05", "2024-05", false},
+		{"?from=2024-01&to=2024-03", "2024-01", "2024-03", false},
+		{"?from=2024-04&to=2024-03", "", "", true},
+		{"?from=2022-01&to=2024-12", "2022-01", "2024-12", false}, // Exactly 36 months
+		{"?from=2022-01&to=2025-01", "", "", true},
+		{"?from=2024-13&to=2024-12", "", "", true},
+		{"?to=May", "", "", true},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/reports/budget-variance"+tt.query, nil)
+		from, to, err := varianceMonths(c, time.UTC)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: got %s to %s, want an error", tt.query, from.Format("2006-01"), to.Format("2006-01"))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		if from.Format("2006-01") != tt.wantFrom || to.Format("2006-01") != tt.wantTo {
+			t.Errorf("%q: got %s to %s, want %s to %s", tt.query, from.Format("2006-01"), to.Format("2006-01"), tt.wantFrom, tt.wantTo)
+		}
+	}
+}
+
+func TestOverBudgetCategoriesNeedTwoMonths(t *testing.T) {
+	food := Category{ID: 1, Name: "Food"}
+	rent := Category{ID: 2, Name: "Rent"}
+	travel := Category{ID: 3, Name: "Travel"}
+	rows := []BudgetVariance{
+		{Month: "2024-01", Category: food, VarianceAmounts: newVarianceAmounts(100, 150)},
+		{Month: "2024-02", Category: food, VarianceAmounts: newVarianceAmounts(100, 90)},
+		{Month: "2024-03", Category: food, VarianceAmounts: newVarianceAmounts(100, 120)},
+		// Over budget only once
+		{Month: "2024-01", Category: rent, VarianceAmounts: newVarianceAmounts(500, 600)},
+		{Month: "2024-02", Category: rent, VarianceAmounts: newVarianceAmounts(500, 500)},
+		// Spending without a budget is not counted as going over it
+		{Month: "2024-01", Category: travel, VarianceAmounts: newVarianceAmounts(0, 300)},
+		{Month: "2024-02", Category: travel, VarianceAmounts: newVarianceAmounts(0, 200)},
+	}
+
+	got := overBudgetCategories(rows)
+	want := []OverBudgetCategory{{Category: food, Months: []string{"2024-01", "2024-03"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("over budget = %+v, want %+v", got, want)
+	}
+
+	if got := overBudgetCategories(nil); got == nil || len(got) != 0 {
+		t.Errorf("no rows gave %#v, want an empty list", got)
+	}
+}